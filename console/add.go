@@ -13,6 +13,7 @@ type CmdAdd struct {
 	Type  string
 	Value string
 	TTL   int64
+	Line  string
 }
 
 func (cmd *CmdAdd) init() error {
@@ -25,6 +26,7 @@ func (cmd *CmdAdd) init() error {
 	cmd.flagSet.StringVar(&cmd.Type, "type", "", "domain name record type")
 	cmd.flagSet.StringVar(&cmd.Value, "value", "", "value of domain name record")
 	cmd.flagSet.Int64Var(&cmd.TTL, "ttl", 0, "TTL(Time-To-Live), Retention time of domain name records in DNS servers.")
+	cmd.flagSet.StringVar(&cmd.Line, "line", "", "resolution line, such as 'telecom', see 'alidns lines ls'")
 
 	return nil
 }
@@ -52,7 +54,7 @@ func (cmd *CmdAdd) Check() error {
 
 func (cmd *CmdAdd) Execute() error {
 
-	api, err := utility.NewAlidnsApi(cmd.DomainName, cmd.AccessKeyId, cmd.AccessKeySecret)
+	api, err := cmd.newApi()
 	if err != nil {
 		return err
 	}
@@ -67,10 +69,15 @@ func (cmd *CmdAdd) Execute() error {
 		record.TTL = &cmd.TTL
 	}
 
+	if cmd.Line != "" {
+		record.Line = &cmd.Line
+	}
+
 	if record, err = api.Add(record); err != nil {
 		return err
 	}
 
+	cmd.dryRunNotice()
 	fmt.Printf(
 		"Add domain name record successfully!\n"+
 			"ID:     %s\n"+
@@ -79,6 +86,7 @@ func (cmd *CmdAdd) Execute() error {
 			"TYPE:   %s\n"+
 			"VALUE:  %s\n"+
 			"TTL:    %d\n"+
+			"LINE:   %s\n"+
 			"STATUS: %s\n",
 		*record.RecordId,
 		*record.DomainName,
@@ -86,6 +94,7 @@ func (cmd *CmdAdd) Execute() error {
 		*record.Type,
 		*record.Value,
 		*record.TTL,
+		utility.LineValue(record.Line),
 		*record.Status,
 	)
 
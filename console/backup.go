@@ -0,0 +1,441 @@
+package console
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+	"github.com/olekukonko/tablewriter"
+)
+
+// snapshotTimeFormat names the snapshot files, so that they sort by time.
+const snapshotTimeFormat = "20060102T150405Z"
+
+// Snapshot is every record of a domain at a point in time.
+type Snapshot struct {
+	DomainName string                  `json:"DomainName"`
+	Created    time.Time               `json:"Created"`
+	Records    []*utility.DomainRecord `json:"Records"`
+}
+
+func defaultSnapshotDir() string {
+	if user, err := user.Current(); err == nil {
+		return filepath.Join(user.HomeDir, ".alidns-snapshots")
+	}
+	return ".alidns-snapshots"
+}
+
+type CmdBackup struct {
+	Cmd
+	Dir string
+}
+
+func (cmd *CmdBackup) init() error {
+
+	if err := cmd.Cmd.init("backup"); err != nil {
+		return err
+	}
+
+	cmd.flagSet.StringVar(&cmd.Dir, "dir", defaultSnapshotDir(), "directory of the snapshots, they are written to DIR/DOMAIN/TIME.json")
+
+	return nil
+}
+
+func (cmd *CmdBackup) Execute() error {
+
+	api, err := cmd.newApi()
+	if err != nil {
+		return err
+	}
+
+	records, err := api.Query(&utility.QueryInfo{})
+	if err != nil {
+		return err
+	}
+
+	snapshot := &Snapshot{
+		DomainName: cmd.DomainName,
+		Created:    time.Now().UTC(),
+		Records:    records,
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(cmd.Dir, cmd.DomainName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	fileName := filepath.Join(dir, snapshot.Created.Format(snapshotTimeFormat)+".json")
+	// write the snapshot aside, so that a snapshot file is never partial
+	if err := os.WriteFile(fileName+".tmp", data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(fileName+".tmp", fileName); err != nil {
+		return err
+	}
+
+	fmt.Printf("The %d domain name records of '%s' were saved to '%s'.\n", len(records), cmd.DomainName, fileName)
+	return nil
+}
+
+func NewCmdBackup() *CmdBackup {
+	cmd := CmdBackup{}
+	if err := cmd.init(); err != nil {
+		panic(err)
+	} else {
+		return &cmd
+	}
+}
+
+// change is a step of a restore plan.
+type change struct {
+	action string
+	old    *utility.DomainRecord
+	new    *utility.DomainRecord
+	note   string
+}
+
+type CmdRestore struct {
+	Cmd
+	Dir       string
+	KeepAdded bool
+	snapshot  string
+}
+
+func (cmd *CmdRestore) init() error {
+
+	if err := cmd.Cmd.init("restore"); err != nil {
+		return err
+	}
+
+	cmd.flagSet.StringVar(&cmd.Dir, "dir", defaultSnapshotDir(), "directory of the snapshots written by 'alidns backup'")
+	cmd.flagSet.BoolVar(&cmd.KeepAdded, "keep-added", false, "keep the records added since the snapshot instead of deleting them")
+	cmd.confirmVar()
+
+	usage := cmd.flagSet.Usage
+	cmd.flagSet.Usage = func() {
+		fmt.Println("Usage:  alidns restore SNAPSHOT [OPTIONS]")
+		fmt.Println("SNAPSHOT is the file of a snapshot, its name in DIR/DOMAIN such as '20261019T133000Z', or 'latest'.")
+		usage()
+	}
+
+	return nil
+}
+
+func (cmd *CmdRestore) Parse(arguments []string) error {
+
+	// the snapshot may come before or after the options
+	if len(arguments) > 0 && !strings.HasPrefix(arguments[0], "-") {
+		cmd.snapshot = arguments[0]
+		arguments = arguments[1:]
+	}
+
+	if err := cmd.Cmd.Parse(arguments); err != nil {
+		return err
+	}
+
+	if cmd.snapshot == "" && cmd.flagSet.NArg() > 0 {
+		cmd.snapshot = cmd.flagSet.Arg(0)
+	}
+
+	return nil
+}
+
+func (cmd *CmdRestore) Check() error {
+
+	if err := cmd.Cmd.Check(); err != nil {
+		return err
+	}
+
+	if cmd.snapshot == "" {
+		return errors.New("the snapshot must be specified")
+	}
+
+	return nil
+}
+
+// snapshotFile resolves the snapshot argument to a file.
+func (cmd *CmdRestore) snapshotFile() (string, error) {
+	if _, err := os.Stat(cmd.snapshot); err == nil {
+		return cmd.snapshot, nil
+	}
+
+	dir := filepath.Join(cmd.Dir, cmd.DomainName)
+	if cmd.snapshot == "latest" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return "", err
+		}
+		if len(files) == 0 {
+			return "", notFoundError{fmt.Errorf("there is no snapshot in '%s'", dir)}
+		}
+		sort.Strings(files)
+		return files[len(files)-1], nil
+	}
+
+	fileName := filepath.Join(dir, strings.TrimSuffix(cmd.snapshot, ".json")+".json")
+	if _, err := os.Stat(fileName); err != nil {
+		return "", notFoundError{fmt.Errorf("the snapshot '%s' does not exist", cmd.snapshot)}
+	}
+	return fileName, nil
+}
+
+func (cmd *CmdRestore) Execute() error {
+
+	fileName, err := cmd.snapshotFile()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return fmt.Errorf("failed to read the snapshot '%s': %s", fileName, err.Error())
+	}
+	if snapshot.DomainName != cmd.DomainName {
+		return fmt.Errorf("the snapshot '%s' is of the domain '%s', not '%s'", fileName, snapshot.DomainName, cmd.DomainName)
+	}
+
+	api, err := cmd.newApi()
+	if err != nil {
+		return err
+	}
+
+	records, err := api.Query(&utility.QueryInfo{})
+	if err != nil {
+		return err
+	}
+
+	plan := cmd.plan(snapshot.Records, records)
+	if len(plan) == 0 {
+		fmt.Printf("The domain name records of '%s' are the same as in the snapshot of %s.\n",
+			cmd.DomainName, snapshot.Created.Local().Format(time.RFC3339))
+		return nil
+	}
+
+	fmt.Printf("Restoring the snapshot of %s makes the following changes:\n", snapshot.Created.Local().Format(time.RFC3339))
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ACTION", "ID", "RR", "TYPE", "LINE", "VALUE", "CHANGES"})
+	for _, c := range plan {
+		record := c.new
+		if record == nil {
+			record = c.old
+		}
+		table.Append([]string{
+			c.action,
+			tea.StringValue(record.RecordId),
+			tea.StringValue(record.RR),
+			tea.StringValue(record.Type),
+			utility.LineValue(record.Line),
+			tea.StringValue(record.Value),
+			c.note,
+		})
+	}
+	table.Render()
+
+	// the skipped steps are only reported
+	steps := 0
+	var changed []*recordChange
+	for _, c := range plan {
+		if c.action != "skip" {
+			steps++
+		}
+		switch c.action {
+		case "delete":
+			changed = append(changed, &recordChange{before: c.old})
+		case "revert":
+			changed = append(changed, &recordChange{before: c.new, after: c.old})
+		}
+	}
+	if steps == 0 {
+		fmt.Println("No change can be applied.")
+		return ExitFailure
+	}
+	if err := cmd.checkProtected(changed); err != nil {
+		return err
+	}
+	if err := cmd.ask(fmt.Sprintf("Apply these %d changes?", steps)); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, c := range plan {
+		if c.action == "skip" {
+			continue
+		}
+		if err := cmd.apply(api, c); err != nil {
+			failed++
+			fmt.Printf("Failed to %s the domain name record '%s' (%s): %s\n", c.action,
+				tea.StringValue(c.old.RR), tea.StringValue(c.old.RecordId), utility.ErrMsg(err))
+		}
+	}
+
+	cmd.dryRunNotice()
+	if failed > 0 {
+		fmt.Printf("%d of %d changes failed.\n", failed, steps)
+		return ExitFailure
+	}
+	fmt.Printf("The snapshot '%s' was successfully restored!\n", fileName)
+	return nil
+}
+
+// plan compares the records of the snapshot with the current ones, matched by
+// RecordId, or by RR, Type, Line and Value for a record deleted and added again
+// since. The records deleted since are re-created, the modified ones are
+// reverted and the added ones are deleted unless -keep-added is specified.
+// The deletions come first, so that a record is never created next to the
+// record it would duplicate.
+func (cmd *CmdRestore) plan(saved []*utility.DomainRecord, current []*utility.DomainRecord) []*change {
+	byId := map[string]*utility.DomainRecord{}
+	for _, record := range current {
+		byId[tea.StringValue(record.RecordId)] = record
+	}
+
+	var reverts, creates, deletes []*change
+	revert := func(old *utility.DomainRecord, record *utility.DomainRecord) {
+		// the record is reverted from its current attributes to those saved
+		diffs := diffRecords(record, old)
+		if len(diffs) == 0 {
+			return
+		}
+		if tea.BoolValue(record.Locked) {
+			diffs = append(diffs, "locked, cannot be reverted")
+			reverts = append(reverts, &change{action: "skip", old: old, new: record, note: strings.Join(diffs, ", ")})
+			return
+		}
+		reverts = append(reverts, &change{action: "revert", old: old, new: record, note: strings.Join(diffs, ", ")})
+	}
+
+	var missing []*utility.DomainRecord
+	for _, old := range saved {
+		record, ok := byId[tea.StringValue(old.RecordId)]
+		if !ok {
+			missing = append(missing, old)
+			continue
+		}
+		delete(byId, tea.StringValue(old.RecordId))
+		revert(old, record)
+	}
+
+	// a record deleted and added again has a new RecordId but the same content
+	for _, old := range missing {
+		var match *utility.DomainRecord
+		for _, record := range current {
+			if _, ok := byId[tea.StringValue(record.RecordId)]; ok && sameContent(old, record) {
+				match = record
+				break
+			}
+		}
+		if match == nil {
+			creates = append(creates, &change{action: "create", old: old, note: "deleted since the snapshot"})
+			continue
+		}
+		delete(byId, tea.StringValue(match.RecordId))
+		revert(old, match)
+	}
+
+	if !cmd.KeepAdded {
+		for _, record := range current {
+			if _, ok := byId[tea.StringValue(record.RecordId)]; ok {
+				deletes = append(deletes, &change{action: "delete", old: record, note: "added since the snapshot"})
+			}
+		}
+	}
+
+	return append(append(deletes, reverts...), creates...)
+}
+
+// sameContent tells whether two records hold the same value for the same RR,
+// type and line, Alidns refuses to hold both.
+func sameContent(a *utility.DomainRecord, b *utility.DomainRecord) bool {
+	return tea.StringValue(a.RR) == tea.StringValue(b.RR) &&
+		tea.StringValue(a.Type) == tea.StringValue(b.Type) &&
+		utility.LineValue(a.Line) == utility.LineValue(b.Line) &&
+		tea.StringValue(a.Value) == tea.StringValue(b.Value)
+}
+
+// apply executes a step of a restore plan.
+func (cmd *CmdRestore) apply(api *utility.AlidnsApi, c *change) error {
+	old := c.old
+	switch c.action {
+	case "delete":
+		return api.Delete(tea.StringValue(old.RecordId))
+	case "create":
+		added, err := api.Add(&utility.DomainRecord{
+			RR:       old.RR,
+			Type:     old.Type,
+			Value:    old.Value,
+			TTL:      old.TTL,
+			Line:     old.Line,
+			Priority: old.Priority,
+		})
+		if err != nil {
+			return err
+		}
+		return cmd.restoreAttributes(api, tea.StringValue(added.RecordId), old, &utility.DomainRecord{Status: tea.String("ENABLE")})
+	case "revert":
+		record := c.new
+		if tea.StringValue(old.RR) != tea.StringValue(record.RR) ||
+			tea.StringValue(old.Type) != tea.StringValue(record.Type) ||
+			tea.StringValue(old.Value) != tea.StringValue(record.Value) ||
+			tea.Int64Value(old.TTL) != tea.Int64Value(record.TTL) ||
+			utility.LineValue(old.Line) != utility.LineValue(record.Line) ||
+			tea.Int64Value(old.Priority) != tea.Int64Value(record.Priority) {
+			if err := api.Update(&utility.DomainRecord{
+				RecordId: record.RecordId,
+				RR:       old.RR,
+				Type:     old.Type,
+				Value:    old.Value,
+				TTL:      old.TTL,
+				Line:     old.Line,
+				Priority: old.Priority,
+			}); err != nil {
+				return err
+			}
+		}
+		return cmd.restoreAttributes(api, tea.StringValue(record.RecordId), old, record)
+	}
+	return nil
+}
+
+// restoreAttributes restores the remark, the status and the weight of a
+// record, which are not set by adding or updating it.
+func (cmd *CmdRestore) restoreAttributes(api *utility.AlidnsApi, recordId string, old *utility.DomainRecord, record *utility.DomainRecord) error {
+	if tea.StringValue(old.Remark) != tea.StringValue(record.Remark) {
+		if err := api.SetRemark(recordId, tea.StringValue(old.Remark)); err != nil {
+			return err
+		}
+	}
+	if status := strings.ToUpper(tea.StringValue(old.Status)); status != "" && status != strings.ToUpper(tea.StringValue(record.Status)) {
+		if err := api.SetStatus(recordId, status == "ENABLE"); err != nil {
+			return err
+		}
+	}
+	if old.Weight != nil && record.Weight != nil && *old.Weight != *record.Weight {
+		if err := api.SetWeight(recordId, *old.Weight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewCmdRestore() *CmdRestore {
+	cmd := CmdRestore{}
+	if err := cmd.init(); err != nil {
+		panic(err)
+	} else {
+		return &cmd
+	}
+}
@@ -0,0 +1,76 @@
+package console
+
+import (
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+)
+
+func testRecord(id string, rr string, value string) *utility.DomainRecord {
+	return &utility.DomainRecord{
+		RecordId: tea.String(id),
+		RR:       tea.String(rr),
+		Type:     tea.String("A"),
+		Value:    tea.String(value),
+		TTL:      tea.Int64(600),
+		Line:     tea.String("default"),
+		Status:   tea.String("ENABLE"),
+	}
+}
+
+func TestRestorePlan(t *testing.T) {
+	saved := []*utility.DomainRecord{
+		testRecord("1", "www", "203.0.113.1"),
+		testRecord("2", "api", "203.0.113.2"),
+		testRecord("3", "mail", "203.0.113.3"),
+		testRecord("4", "ftp", "203.0.113.4"),
+	}
+	current := []*utility.DomainRecord{
+		testRecord("1", "www", "203.0.113.1"),
+		// modified since the snapshot
+		testRecord("2", "api", "203.0.113.20"),
+		// deleted and added again with the same content
+		testRecord("30", "mail", "203.0.113.3"),
+		// added since the snapshot
+		testRecord("5", "new", "203.0.113.5"),
+	}
+	// ftp was deleted since the snapshot
+
+	plan := (&CmdRestore{}).plan(saved, current)
+	var got []string
+	for _, c := range plan {
+		record := c.new
+		if record == nil {
+			record = c.old
+		}
+		got = append(got, c.action+" "+tea.StringValue(record.RecordId))
+	}
+	want := []string{"delete 5", "revert 2", "create 4"}
+	if len(got) != len(want) {
+		t.Fatalf("got plan %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got plan %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRestorePlanReAdded(t *testing.T) {
+	saved := []*utility.DomainRecord{testRecord("1", "www", "203.0.113.1")}
+	readded := testRecord("2", "www", "203.0.113.1")
+	readded.TTL = tea.Int64(60)
+
+	// the record added again is reverted, not deleted and created again
+	plan := (&CmdRestore{}).plan(saved, []*utility.DomainRecord{readded})
+	if len(plan) != 1 || plan[0].action != "revert" || tea.StringValue(plan[0].new.RecordId) != "2" {
+		t.Fatalf("got plan %+v", plan)
+	}
+
+	// with -keep-added, a record of the same content is not created again
+	plan = (&CmdRestore{KeepAdded: true}).plan(saved, []*utility.DomainRecord{testRecord("2", "www", "203.0.113.1")})
+	if len(plan) != 0 {
+		t.Fatalf("got plan %+v, want no change", plan)
+	}
+}
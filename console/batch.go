@@ -0,0 +1,498 @@
+package console
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+)
+
+// Operation is a record operation of a batch: add, mod, rm, enable or disable.
+type Operation struct {
+	Op       string `json:"Op"`
+	RecordId string `json:"RecordId"`
+	RR       string `json:"RR"`
+	Type     string `json:"Type"`
+	Value    string `json:"Value"`
+	TTL      int64  `json:"TTL"`
+	Line     string `json:"Line"`
+}
+
+func (op *Operation) check() error {
+	switch op.Op {
+	case "add":
+		if op.RR == "" {
+			return errors.New("RR must be specified")
+		}
+		if !utility.IsTypeValid(op.Type) {
+			return errors.New("the domain name record type is invalid or not specified")
+		}
+		if op.Value == "" {
+			return errors.New("domain name record value not specified")
+		}
+	case "mod", "rm", "enable", "disable":
+		if op.RecordId == "" {
+			return errors.New("domain name record id must be specified")
+		}
+		if op.Type != "" && !utility.IsTypeValid(op.Type) {
+			return errors.New("the domain name record type is invalid")
+		}
+	default:
+		return fmt.Errorf("unknown operation '%s', it must be one of add, mod, rm, enable and disable", op.Op)
+	}
+	return nil
+}
+
+// destructive tells whether the operation removes, modifies or disables a
+// record, such operations are confirmed before the batch is applied.
+func (op *Operation) destructive() bool {
+	return op.Op == "rm" || op.Op == "mod" || op.Op == "disable"
+}
+
+// BatchResult is the outcome of the operation of a line of the batch.
+type BatchResult struct {
+	Line     int    `json:"Line"`
+	Op       string `json:"Op,omitempty"`
+	RecordId string `json:"RecordId,omitempty"`
+	Result   string `json:"Result"`
+	Error    string `json:"Error,omitempty"`
+	// index is the position of the operation in the input
+	index int
+}
+
+// batchLine is an operation read from the input, or the error reading it.
+type batchLine struct {
+	index int
+	line  int
+	op    *Operation
+	err   error
+}
+
+type CmdBatch struct {
+	Cmd
+	File        string
+	Format      string
+	Concurrency int
+	Rate        float64
+}
+
+func (cmd *CmdBatch) init() error {
+
+	if err := cmd.Cmd.init("batch"); err != nil {
+		return err
+	}
+
+	cmd.flagSet.StringVar(&cmd.File, "file", "-", "file of the operations, '-' reads them from stdin")
+	cmd.flagSet.StringVar(&cmd.Format, "format", "", "format of the operations, 'json' (one object per line) or 'csv' (with a header line), "+
+		"guessed from the file name or the first line if not specified")
+	cmd.flagSet.IntVar(&cmd.Concurrency, "concurrency", 4, "number of operations executed at the same time")
+	cmd.flagSet.Float64Var(&cmd.Rate, "rate", 10, "maximum number of operations started per second, 0 is unlimited")
+	cmd.confirmVar()
+
+	usage := cmd.flagSet.Usage
+	cmd.flagSet.Usage = func() {
+		fmt.Println("Usage:  alidns batch [OPTIONS]")
+		fmt.Println("Applies the add, mod, rm, enable and disable operations of a file, one per line, such as:")
+		fmt.Println(`  {"Op": "add", "RR": "www", "Type": "A", "Value": "203.0.113.10", "TTL": 600}`)
+		fmt.Println(`  {"Op": "rm", "RecordId": "123456789"}`)
+		fmt.Println("or in CSV with the columns op, id, rr, type, value, ttl and line:")
+		fmt.Println("  op,id,rr,type,value")
+		fmt.Println("  add,,www,A,203.0.113.10")
+		fmt.Println("A result is printed as a JSON object for every line, the exit status is 1 if any operation failed.")
+		fmt.Println("The rm, mod and disable operations are confirmed before any is applied, -yes confirms them in advance")
+		fmt.Println("and is required when the operations are read from stdin. The operations on the records of the protected")
+		fmt.Println("RRs of the profile fail unless -force is specified.")
+		usage()
+	}
+
+	return nil
+}
+
+func (cmd *CmdBatch) Check() error {
+
+	if err := cmd.Cmd.Check(); err != nil {
+		return err
+	}
+
+	if cmd.Format != "" && cmd.Format != "json" && cmd.Format != "csv" {
+		return fmt.Errorf("unknown format '%s', it must be 'json' or 'csv'", cmd.Format)
+	}
+
+	if cmd.Concurrency < 1 {
+		return errors.New("the concurrency must be at least 1")
+	}
+
+	if cmd.Rate < 0 {
+		return errors.New("the rate cannot be negative")
+	}
+
+	return nil
+}
+
+func (cmd *CmdBatch) Execute() error {
+
+	// the shell reads its lines from the shared stdin, it may hold the input
+	var input io.Reader = stdin
+	if cmd.File != "-" {
+		file, err := os.Open(cmd.File)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	reader := bufio.NewReader(input)
+
+	format := cmd.Format
+	if format == "" {
+		switch {
+		case strings.HasSuffix(strings.ToLower(cmd.File), ".csv"):
+			format = "csv"
+		case strings.HasSuffix(strings.ToLower(cmd.File), ".json"), strings.HasSuffix(strings.ToLower(cmd.File), ".ndjson"):
+			format = "json"
+		default:
+			// a JSON line starts with '{', a CSV stream with its header
+			format = "csv"
+			if lead, err := peekLead(reader); err == nil && lead == '{' {
+				format = "json"
+			}
+		}
+	}
+
+	api, err := cmd.newApi()
+	if err != nil {
+		return err
+	}
+
+	// the whole input is read first, the destructive operations are confirmed
+	// before any operation is applied
+	read := make(chan *batchLine)
+	go func() {
+		defer close(read)
+		if format == "json" {
+			readJSONOperations(reader, read)
+		} else {
+			readCSVOperations(reader, read)
+		}
+	}()
+	var all, destructive []*batchLine
+	for l := range read {
+		all = append(all, l)
+		if l.err == nil && l.op.destructive() {
+			destructive = append(destructive, l)
+		}
+	}
+	if err := cmd.confirmOperations(len(all), destructive); err != nil {
+		return err
+	}
+
+	lines := make(chan *batchLine)
+	go func() {
+		defer close(lines)
+		for _, l := range all {
+			lines <- l
+		}
+	}()
+
+	// the operations are started at most Rate times per second
+	var limiter <-chan time.Time
+	if cmd.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / cmd.Rate))
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	results := make(chan *BatchResult)
+	var wg sync.WaitGroup
+	for i := 0; i < cmd.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for l := range lines {
+				if l.err == nil && limiter != nil {
+					<-limiter
+				}
+				results <- cmd.apply(api, l)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// the results are reported in the order of the lines
+	encoder := json.NewEncoder(os.Stdout)
+	pending := map[int]*BatchResult{}
+	next, failed, total := 0, 0, 0
+	for result := range results {
+		pending[result.index] = result
+		total++
+		if result.Result != "ok" {
+			failed++
+		}
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			encoder.Encode(r)
+		}
+	}
+
+	// the report on stdout is kept machine-readable
+	if cmd.DryRun {
+		fmt.Fprintln(os.Stderr, "Dry run! The domain name records have not been changed.")
+	}
+	fmt.Fprintf(os.Stderr, "%d operations, %d succeeded, %d failed.\n", total, total-failed, failed)
+	if failed > 0 {
+		return ExitFailure
+	}
+	return nil
+}
+
+// confirmOperations asks the user to confirm the destructive operations of
+// the batch, -yes confirms them in advance. The user cannot answer when the
+// operations are read from stdin, -yes is required then.
+func (cmd *CmdBatch) confirmOperations(total int, destructive []*batchLine) error {
+	if len(destructive) == 0 || cmd.Yes || cmd.DryRun {
+		return nil
+	}
+	if cmd.File == "-" {
+		return usageError{errors.New("the operations are read from stdin, -yes is required to confirm the rm, mod and disable operations")}
+	}
+
+	if interactive() {
+		for _, l := range destructive {
+			fmt.Printf("  line %d: %s %s\n", l.line, l.op.Op, l.op.RecordId)
+		}
+	}
+	return cmd.ask(fmt.Sprintf("%d of the %d operations remove, modify or disable domain name records, apply them?", len(destructive), total))
+}
+
+// apply executes the operation of a line.
+func (cmd *CmdBatch) apply(api *utility.AlidnsApi, l *batchLine) *BatchResult {
+	result := &BatchResult{Line: l.line, Result: "failed", index: l.index}
+
+	err := l.err
+	if err == nil {
+		result.Op = l.op.Op
+		result.RecordId = l.op.RecordId
+		if err = l.op.check(); err == nil {
+			err = cmd.execute(api, l.op, result)
+		}
+	}
+	if err != nil {
+		if e, ok := err.(*tea.SDKError); ok {
+			result.Error = fmt.Sprintf("ErrCode: %s, %s", tea.StringValue(e.Code), tea.StringValue(e.Message))
+		} else {
+			result.Error = err.Error()
+		}
+	} else {
+		result.Result = "ok"
+	}
+	return result
+}
+
+func (cmd *CmdBatch) execute(api *utility.AlidnsApi, op *Operation, result *BatchResult) error {
+	switch op.Op {
+	case "add":
+		record := &utility.DomainRecord{
+			RR:    tea.String(op.RR),
+			Type:  tea.String(op.Type),
+			Value: tea.String(op.Value),
+		}
+		if op.TTL > 0 {
+			record.TTL = tea.Int64(op.TTL)
+		}
+		if op.Line != "" {
+			record.Line = tea.String(op.Line)
+		}
+		added, err := api.Add(record)
+		if err != nil {
+			return err
+		}
+		result.RecordId = tea.StringValue(added.RecordId)
+		return nil
+	case "mod":
+		record, err := api.Retrieve(op.RecordId)
+		if err != nil {
+			return err
+		}
+		before := *record
+		if op.RR != "" {
+			record.RR = tea.String(op.RR)
+		}
+		if op.Type != "" {
+			record.Type = tea.String(op.Type)
+		}
+		if op.Value != "" {
+			record.Value = tea.String(op.Value)
+		}
+		if op.TTL > 0 {
+			record.TTL = tea.Int64(op.TTL)
+		}
+		if op.Line != "" {
+			record.Line = tea.String(op.Line)
+		}
+		if err := cmd.checkProtected([]*recordChange{{before: &before, after: record}}); err != nil {
+			return err
+		}
+		return api.Update(record)
+	case "rm":
+		if len(cmd.Protected) > 0 && !cmd.Force {
+			record, err := api.Retrieve(op.RecordId)
+			if err != nil {
+				return err
+			}
+			if err := cmd.checkProtected([]*recordChange{{before: record}}); err != nil {
+				return err
+			}
+		}
+		return api.Delete(op.RecordId)
+	case "disable":
+		if len(cmd.Protected) > 0 && !cmd.Force {
+			record, err := api.Retrieve(op.RecordId)
+			if err != nil {
+				return err
+			}
+			if err := cmd.checkProtectedRR(tea.StringValue(record.RR), "disable"); err != nil {
+				return err
+			}
+		}
+		return api.SetStatus(op.RecordId, false)
+	default:
+		return api.SetStatus(op.RecordId, true)
+	}
+}
+
+// peekLead returns the first character of the input that is neither a space
+// nor in a comment line, without consuming the input.
+func peekLead(reader *bufio.Reader) (byte, error) {
+	comment := false
+	for n := 1; ; n++ {
+		buf, err := reader.Peek(n)
+		if err != nil {
+			return 0, err
+		}
+		switch c := buf[n-1]; {
+		case comment:
+			comment = c != '\n'
+		case c == '#':
+			comment = true
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			return c, nil
+		}
+	}
+}
+
+// readJSONOperations reads a JSON object per line, the blank lines and the
+// lines starting with '#' are skipped.
+func readJSONOperations(reader io.Reader, lines chan<- *batchLine) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	index, number := 0, 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		l := &batchLine{index: index, line: number, op: &Operation{}}
+		if err := json.Unmarshal([]byte(text), l.op); err != nil {
+			l.op, l.err = nil, err
+		}
+		lines <- l
+		index++
+	}
+	if err := scanner.Err(); err != nil {
+		lines <- &batchLine{index: index, line: number + 1, err: err}
+	}
+}
+
+// readCSVOperations reads CSV records, the first one names the columns.
+func readCSVOperations(reader io.Reader, lines chan<- *batchLine) {
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	header, err := r.Read()
+	if err != nil {
+		if err != io.EOF {
+			lines <- &batchLine{index: 0, line: 1, err: err}
+		}
+		return
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "recordid" {
+			name = "id"
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["op"]; !ok {
+		lines <- &batchLine{index: 0, line: 1, err: errors.New("the CSV header has no 'op' column")}
+		return
+	}
+
+	for index := 0; ; index++ {
+		fields, err := r.Read()
+		if err == io.EOF {
+			return
+		}
+		number, _ := r.FieldPos(0)
+		l := &batchLine{index: index, line: number}
+		if err != nil {
+			l.err = err
+			if e, ok := err.(*csv.ParseError); ok {
+				l.line = e.StartLine
+			}
+			lines <- l
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+		l.op = &Operation{
+			Op:       field("op"),
+			RecordId: field("id"),
+			RR:       field("rr"),
+			Type:     field("type"),
+			Value:    field("value"),
+			Line:     field("line"),
+		}
+		if ttl := field("ttl"); ttl != "" {
+			if l.op.TTL, err = strconv.ParseInt(ttl, 10, 64); err != nil {
+				l.op, l.err = nil, fmt.Errorf("the TTL '%s' is not a number", ttl)
+			}
+		}
+		lines <- l
+	}
+}
+
+func NewCmdBatch() *CmdBatch {
+	cmd := CmdBatch{}
+	if err := cmd.init(); err != nil {
+		panic(err)
+	} else {
+		return &cmd
+	}
+}
@@ -0,0 +1,74 @@
+package console
+
+import (
+	"testing"
+)
+
+func TestConfirmOperations(t *testing.T) {
+	rm := &batchLine{line: 2, op: &Operation{Op: "rm", RecordId: "1"}}
+
+	cmd := NewCmdBatch()
+	if err := cmd.confirmOperations(3, nil); err != nil {
+		t.Errorf("a batch without destructive operations asks for confirmation: %v", err)
+	}
+
+	// the user cannot answer on stdin, it is the input of the operations
+	if _, ok := cmd.confirmOperations(3, []*batchLine{rm}).(usageError); !ok {
+		t.Error("the destructive operations read from stdin are not refused without -yes")
+	}
+
+	cmd.Yes = true
+	if err := cmd.confirmOperations(3, []*batchLine{rm}); err != nil {
+		t.Errorf("-yes does not confirm the operations: %v", err)
+	}
+}
+
+func TestDestructiveOperations(t *testing.T) {
+	for op, want := range map[string]bool{"add": false, "enable": false, "mod": true, "rm": true, "disable": true} {
+		if got := (&Operation{Op: op}).destructive(); got != want {
+			t.Errorf("%s: got destructive %v, want %v", op, got, want)
+		}
+	}
+}
+
+func TestCheckProtectedRR(t *testing.T) {
+	cmd := NewCmdBatch()
+	cmd.Protected = []string{"@", "mail*"}
+
+	if err := cmd.checkProtectedRR("www", "modify"); err != nil {
+		t.Errorf("www is not protected: %v", err)
+	}
+	for _, rr := range []string{"@", "mail", "mail2"} {
+		if _, ok := cmd.checkProtectedRR(rr, "disable").(usageError); !ok {
+			t.Errorf("%s is protected", rr)
+		}
+	}
+
+	cmd.Force = true
+	if err := cmd.checkProtectedRR("@", "modify"); err != nil {
+		t.Errorf("-force does not allow the change: %v", err)
+	}
+}
+
+func TestCheckProtected(t *testing.T) {
+	cmd := NewCmdMod()
+	cmd.Protected = []string{"@"}
+
+	www, apex := testRecord("1", "www", "203.0.113.1"), testRecord("2", "@", "203.0.113.2")
+	moved := testRecord("1", "@", "203.0.113.1")
+	for name, test := range map[string]struct {
+		change    *recordChange
+		protected bool
+	}{
+		"add":            {&recordChange{after: apex}, false},
+		"delete":         {&recordChange{before: apex}, true},
+		"modify":         {&recordChange{before: apex, after: apex}, true},
+		"move to the RR": {&recordChange{before: www, after: moved}, true},
+		"other RR":       {&recordChange{before: www}, false},
+	} {
+		err := cmd.checkProtected([]*recordChange{test.change})
+		if _, ok := err.(usageError); ok != test.protected {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package console
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+)
+
+// recordCacheTTL is how long the records cached for the shell completion are used.
+const recordCacheTTL = 10 * time.Minute
+
+// cachedRecord is what the completion needs to know of a record.
+type cachedRecord struct {
+	RecordId string `json:"RecordId"`
+	RR       string `json:"RR"`
+	Type     string `json:"Type"`
+}
+
+// recordCache is the records of a domain, saved by 'ls' and the completion so
+// that completing a command does not query Alidns every time.
+type recordCache struct {
+	Updated time.Time      `json:"Updated"`
+	Records []cachedRecord `json:"Records"`
+}
+
+func recordCacheFile(domainName string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "alidns", domainName+".json"), nil
+}
+
+// loadRecordCache returns the cached records of the domain, nil if there are
+// none or if they are too old.
+func loadRecordCache(domainName string) *recordCache {
+	fileName, err := recordCacheFile(domainName)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil
+	}
+	cache := &recordCache{}
+	if err := json.Unmarshal(data, cache); err != nil || time.Since(cache.Updated) > recordCacheTTL {
+		return nil
+	}
+	return cache
+}
+
+// saveRecordCache caches the records of the domain, failures are ignored as
+// the cache is only a convenience.
+func saveRecordCache(domainName string, records []*utility.DomainRecord) *recordCache {
+	cache := &recordCache{Updated: time.Now(), Records: make([]cachedRecord, 0, len(records))}
+	for _, record := range records {
+		cache.Records = append(cache.Records, cachedRecord{
+			RecordId: tea.StringValue(record.RecordId),
+			RR:       tea.StringValue(record.RR),
+			Type:     tea.StringValue(record.Type),
+		})
+	}
+
+	fileName, err := recordCacheFile(domainName)
+	if err != nil {
+		return cache
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		return cache
+	}
+	if data, err := json.Marshal(cache); err == nil {
+		os.WriteFile(fileName, data, 0600)
+	}
+	return cache
+}
@@ -1,11 +1,19 @@
 package console
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
+	"io/fs"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
 )
 
 const (
@@ -14,6 +22,120 @@ const (
 	envDomainName      = "ALIDNS_DOMAINNAME"
 )
 
+// ExitCode is returned by Command.Execute to make the program exit with the
+// given status code, the command has already reported the outcome itself.
+type ExitCode int
+
+// The exit status of the program, so that the scripts running it can tell
+// why it failed.
+const (
+	// ExitFailure is returned when the command failed for another reason,
+	// or when the user declined a confirmation.
+	ExitFailure ExitCode = 1
+	// ExitUsage is returned for a wrong command line.
+	ExitUsage ExitCode = 2
+	// ExitUnchanged is returned by 'ddns -once' when no record needed an update.
+	ExitUnchanged ExitCode = 3
+	// ExitAuth is returned when the credentials are missing, or refused by Alidns.
+	ExitAuth ExitCode = 4
+	// ExitNotFound is returned when the domain, the record or a file does not exist.
+	ExitNotFound ExitCode = 5
+	// ExitApi is returned for the other errors of the Alidns API.
+	ExitApi ExitCode = 6
+)
+
+func (code ExitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(code))
+}
+
+// flagError is an error of the flags, the flag package has already reported it.
+type flagError struct {
+	error
+}
+
+// authError is an error of the credentials found before any request is sent.
+type authError struct {
+	error
+}
+
+// usageError is an error of the command line found by Execute, such as a
+// missing -yes.
+type usageError struct {
+	error
+}
+
+// notFoundError is an error of something the command line names but that does not exist.
+type notFoundError struct {
+	error
+}
+
+// exitCodeOf returns the exit status of the program when a command failed with err.
+func exitCodeOf(err error) ExitCode {
+	var code ExitCode
+	switch {
+	case errors.As(err, &code):
+		return code
+	case errors.As(err, &usageError{}):
+		return ExitUsage
+	case errors.As(err, &authError{}), utility.IsAuthError(err):
+		return ExitAuth
+	case errors.As(err, &notFoundError{}), errors.Is(err, fs.ErrNotExist), utility.IsNotFoundError(err):
+		return ExitNotFound
+	}
+	if _, ok := err.(*tea.SDKError); ok {
+		return ExitApi
+	}
+	return ExitFailure
+}
+
+// Options are the options given before the command, they apply to every command.
+type Options struct {
+	Profile  string
+	Output   string
+	Endpoint string
+	Verbose  bool
+}
+
+// options are set by Registry.Run.
+var options = Options{Output: "text"}
+
+// jsonOutput tells whether the listings are printed as JSON.
+func jsonOutput() bool {
+	return options.Output == "json"
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// seconds is a flag.Value of a number of seconds, stored the way the ddns
+// configuration stores its intervals. It accepts '30' as well as '30s' or '5m'.
+type seconds time.Duration
+
+func (s *seconds) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *seconds) Set(value string) error {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		*s = seconds(n)
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*s = seconds(d / time.Second)
+	return nil
+}
+
+func (cmd *Cmd) secondsVar(p *time.Duration, name string, value time.Duration, usage string) {
+	*p = value
+	cmd.flagSet.Var((*seconds)(p), name, usage)
+}
+
 type Command interface {
 	Name() string
 	Parse(arguments []string) error
@@ -27,18 +149,61 @@ type Cmd struct {
 	name    string
 	Profile
 	ProfileName string
+	DryRun      bool
+	// Yes and Force are the flags of the commands confirming their changes
+	Yes   bool
+	Force bool
+	// session is the shell the command runs in, if any
+	session *session
 }
 
 func (cmd *Cmd) init(name string) error {
 	cmd.name = name
-	cmd.flagSet = flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	cmd.flagSet = flag.NewFlagSet(cmd.name, flag.ContinueOnError)
 	cmd.flagSet.StringVar(&cmd.Profile.AccessKeyId, "key", "", "access key id")
 	cmd.flagSet.StringVar(&cmd.Profile.AccessKeySecret, "secret", "", "access key secret")
 	cmd.flagSet.StringVar(&cmd.Profile.DomainName, "domain", "", "domain name")
-	cmd.flagSet.StringVar(&cmd.ProfileName, "profile", "", "A profile containing information such as user authentication.")
+	cmd.flagSet.StringVar(&cmd.ProfileName, "profile", "", "A profile containing information such as user authentication, a file or the name of a profile in ~/.alidns.d.")
+	cmd.flagSet.BoolVar(&cmd.DryRun, "dry-run", false, "log the requests that would modify domain name records instead of sending them")
 	return nil
 }
 
+// attach makes the command run in the shell session, reusing its profile and
+// its client.
+func (cmd *Cmd) attach(s *session) {
+	cmd.session = s
+}
+
+func (cmd *Cmd) flags() *flag.FlagSet {
+	return cmd.flagSet
+}
+
+func (cmd *Cmd) newApi() (*utility.AlidnsApi, error) {
+	if s := cmd.session; s != nil &&
+		cmd.AccessKeyId == s.profile.AccessKeyId && cmd.AccessKeySecret == s.profile.AccessKeySecret {
+		api, err := s.client()
+		if err != nil {
+			return nil, err
+		}
+		api.DomainName = cmd.DomainName
+		api.DryRun = cmd.DryRun
+		return api, nil
+	}
+
+	api, err := utility.NewAlidnsApi(cmd.DomainName, cmd.AccessKeyId, cmd.AccessKeySecret)
+	if err != nil {
+		return nil, err
+	}
+	api.DryRun = cmd.DryRun
+	return api, nil
+}
+
+func (cmd *Cmd) dryRunNotice() {
+	if cmd.DryRun {
+		fmt.Println("Dry run! The domain name records have not been changed.")
+	}
+}
+
 func (cmd *Cmd) Name() string {
 	return cmd.name
 }
@@ -50,11 +215,11 @@ func (cmd *Cmd) Usage() {
 func (cmd *Cmd) Check() error {
 
 	if cmd.AccessKeyId == "" {
-		return errors.New("access key id is not specified")
+		return authError{errors.New("access key id is not specified")}
 	}
 
 	if cmd.AccessKeySecret == "" {
-		return errors.New("access key secret is not specified")
+		return authError{errors.New("access key secret is not specified")}
 	}
 
 	if cmd.DomainName == "" {
@@ -67,11 +232,20 @@ func (cmd *Cmd) Check() error {
 func (cmd *Cmd) Parse(arguments []string) error {
 
 	if err := cmd.flagSet.Parse(arguments); err != nil {
-		return err
+		if err == flag.ErrHelp {
+			return err
+		}
+		return flagError{err}
+	}
+	if cmd.session == nil && cmd.ProfileName == "" {
+		cmd.ProfileName = options.Profile
 	}
 
 	profile := Profile{}
-	if cmd.ProfileName == "" {
+	if cmd.session != nil && cmd.ProfileName == "" {
+		// the profile was loaded once by the shell
+		profile = cmd.session.profile
+	} else if cmd.ProfileName == "" {
 		if user, err := user.Current(); err == nil {
 			fileName := filepath.Join(user.HomeDir, ".alidns")
 			if _, err := os.Stat(fileName); err == nil {
@@ -88,7 +262,7 @@ func (cmd *Cmd) Parse(arguments []string) error {
 			}
 		}
 	} else {
-		profile.Load(cmd.ProfileName)
+		profile.Load(profilePath(cmd.ProfileName))
 	}
 
 	if cmd.AccessKeyId == "" {
@@ -115,5 +289,7 @@ func (cmd *Cmd) Parse(arguments []string) error {
 		}
 	}
 
+	cmd.Protected = profile.Protected
+
 	return nil
 }
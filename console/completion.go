@@ -0,0 +1,411 @@
+package console
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kdiot/alidns-console/utility"
+)
+
+// actionCommand is a command whose first argument is an action, such as
+// 'completion bash'.
+type actionCommand interface {
+	actions() []string
+}
+
+const bashCompletion = `# bash completion for %[1]s, load it with: source <(%[1]s completion bash)
+_%[2]s() {
+	local IFS=$'\n'
+	COMPREPLY=($(%[1]s __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+	if [ ${#COMPREPLY[@]} -eq 0 ]; then
+		compopt -o default
+	fi
+}
+complete -F _%[2]s %[1]s
+`
+
+const zshCompletion = `#compdef %[1]s
+# zsh completion for %[1]s, load it with: source <(%[1]s completion zsh)
+_%[2]s() {
+	local -a candidates
+	candidates=("${(@f)$(%[1]s __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	candidates=(${candidates:#})
+	if (( ${#candidates} )); then
+		compadd -- "${candidates[@]}"
+	else
+		_files
+	fi
+}
+compdef _%[2]s %[1]s
+`
+
+const fishCompletion = `# fish completion for %[1]s, load it with: %[1]s completion fish | source
+function __%[2]s_complete
+	set -l tokens (commandline -opc) (commandline -ct)
+	set -l candidates (%[1]s __complete $tokens[2..-1] 2>/dev/null)
+	if test (count $candidates) -gt 0
+		printf '%%s\n' $candidates
+	else
+		__fish_complete_path (commandline -ct)
+	end
+end
+complete -c %[1]s -f -a '(__%[2]s_complete)'
+`
+
+// CmdCompletion prints the completion script of a shell: 'completion bash|zsh|fish'.
+// The scripts ask 'alidns __complete' for the candidates, so that they follow
+// the registered commands and their flags.
+type CmdCompletion struct {
+	Cmd
+	shell string
+}
+
+func (cmd *CmdCompletion) init() error {
+
+	if err := cmd.Cmd.init("completion"); err != nil {
+		return err
+	}
+
+	usage := cmd.flagSet.Usage
+	cmd.flagSet.Usage = func() {
+		fmt.Println("Usage:  alidns completion bash|zsh|fish")
+		fmt.Println("  bash        source <(alidns completion bash)")
+		fmt.Println("  zsh         source <(alidns completion zsh)")
+		fmt.Println("  fish        alidns completion fish | source")
+		usage()
+	}
+
+	return nil
+}
+
+func (cmd *CmdCompletion) actions() []string {
+	return []string{"bash", "zsh", "fish"}
+}
+
+func (cmd *CmdCompletion) Parse(arguments []string) error {
+
+	if len(arguments) > 0 && !strings.HasPrefix(arguments[0], "-") {
+		cmd.shell = arguments[0]
+		arguments = arguments[1:]
+	}
+
+	return cmd.Cmd.Parse(arguments)
+}
+
+func (cmd *CmdCompletion) Check() error {
+
+	switch cmd.shell {
+	case "bash", "zsh", "fish":
+		return nil
+	case "":
+		return fmt.Errorf("the shell must be one of bash, zsh and fish")
+	default:
+		return fmt.Errorf("unknown shell '%s', it must be one of bash, zsh and fish", cmd.shell)
+	}
+}
+
+func (cmd *CmdCompletion) Execute() error {
+
+	name := filepath.Base(os.Args[0])
+	// the name of the shell function
+	function := strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	switch cmd.shell {
+	case "bash":
+		fmt.Printf(bashCompletion, name, function)
+	case "zsh":
+		fmt.Printf(zshCompletion, name, function)
+	case "fish":
+		fmt.Printf(fishCompletion, name, function)
+	}
+
+	return nil
+}
+
+func NewCmdCompletion() *CmdCompletion {
+	cmd := CmdCompletion{}
+	if err := cmd.init(); err != nil {
+		panic(err)
+	} else {
+		return &cmd
+	}
+}
+
+// CmdComplete prints the candidates completing a command line, one per line,
+// for the completion scripts. Its arguments are the words of the command line
+// after the program name, the last one being the word to complete. It prints
+// nothing when the shell should complete a file name.
+type CmdComplete struct {
+	Cmd
+	registry *Registry
+	words    []string
+}
+
+func (cmd *CmdComplete) init() error {
+	return cmd.Cmd.init("__complete")
+}
+
+func (cmd *CmdComplete) Parse(arguments []string) error {
+	// the words are those of the command line being completed, not flags
+	cmd.words = arguments
+	return nil
+}
+
+func (cmd *CmdComplete) Check() error {
+	return nil
+}
+
+func (cmd *CmdComplete) Execute() error {
+
+	words := cmd.words
+	current := ""
+	if len(words) > 0 {
+		current = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	for _, candidate := range cmd.candidates(words, current) {
+		if strings.HasPrefix(candidate, current) {
+			fmt.Println(candidate)
+		}
+	}
+
+	return nil
+}
+
+func (cmd *CmdComplete) candidates(words []string, current string) []string {
+
+	// the global options come before the command
+	global := cmd.registry.flags(&Options{})
+	i := skipFlags(global, words)
+	if i == len(words) {
+		if name := pendingFlag(global, words); name != "" {
+			return cmd.values(name, "", nil, nil)
+		}
+		if strings.HasPrefix(current, "-") {
+			return flagNames(global, current)
+		}
+		return append(cmd.registry.names(), "help", "version")
+	}
+	globals, words := words[:i], words[i:]
+
+	r := cmd.registry
+	if words[0] == "help" {
+		for _, word := range words[1:] {
+			e := r.lookup(word)
+			if e == nil || e.group == nil {
+				return nil
+			}
+			r = e.group
+		}
+		return r.names()
+	}
+
+	// words[0] is the name of the command once the groups are walked
+	var c Command
+	for c == nil {
+		e := r.lookup(words[0])
+		if e == nil {
+			return nil
+		}
+		if e.group == nil {
+			c = e.command
+			break
+		}
+		r, words = e.group, words[1:]
+		if len(words) == 0 {
+			return r.names()
+		}
+	}
+
+	var flagSet *flag.FlagSet
+	if f, ok := c.(interface{ flags() *flag.FlagSet }); ok {
+		flagSet = f.flags()
+	}
+
+	// the value of the flag before the current word
+	if flagSet != nil {
+		if name := pendingFlag(flagSet, words[1:]); name != "" {
+			return cmd.values(name, strings.TrimSpace(r.path+" "+c.Name()), globals, words[1:])
+		}
+	}
+
+	if strings.HasPrefix(current, "-") {
+		if flagSet == nil {
+			return nil
+		}
+		return flagNames(flagSet, current)
+	}
+
+	if a, ok := c.(actionCommand); ok && len(words) == 1 {
+		return a.actions()
+	}
+	return nil
+}
+
+// skipFlags returns the index of the first word that is neither a flag nor
+// the value of a flag.
+func skipFlags(flagSet *flag.FlagSet, words []string) int {
+	i := 0
+	for i < len(words) && strings.HasPrefix(words[i], "-") {
+		name := strings.TrimLeft(words[i], "-")
+		i++
+		if f := flagSet.Lookup(name); f != nil && !isBoolFlag(f) {
+			i++
+		}
+	}
+	if i > len(words) {
+		return len(words)
+	}
+	return i
+}
+
+// pendingFlag returns the name of the flag the current word is the value of,
+// if the last word is a flag that takes a value.
+func pendingFlag(flagSet *flag.FlagSet, words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	last := words[len(words)-1]
+	if !strings.HasPrefix(last, "-") || strings.Contains(last, "=") {
+		return ""
+	}
+	name := strings.TrimLeft(last, "-")
+	if f := flagSet.Lookup(name); f != nil && !isBoolFlag(f) {
+		return name
+	}
+	return ""
+}
+
+// flagNames returns the flags with as many dashes as the current word.
+func flagNames(flagSet *flag.FlagSet, current string) []string {
+	dashes := "-"
+	if strings.HasPrefix(current, "--") {
+		dashes = "--"
+	}
+	var names []string
+	flagSet.VisitAll(func(f *flag.Flag) {
+		names = append(names, dashes+f.Name)
+	})
+	return names
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// values returns the candidate values of a flag of the command, or of a
+// global option if the command is empty.
+func (cmd *CmdComplete) values(name string, command string, globals []string, args []string) []string {
+	switch name {
+	case "type":
+		if strings.HasPrefix(command, "ddns ") {
+			return []string{"A", "AAAA"}
+		}
+		return utility.RecordTypes
+	case "profile":
+		return profileNames()
+	case "loglvl":
+		return []string{"debug", "info", "warning", "error", "fatal"}
+	case "format":
+		return []string{"json", "csv"}
+	case "output":
+		if command == "" {
+			return []string{"text", "json"}
+		}
+	case "rr", "subdomain", "id":
+		cache := cmd.records(globals, args)
+		if cache == nil {
+			return nil
+		}
+		seen := map[string]bool{}
+		var values []string
+		for _, record := range cache.Records {
+			value := record.RR
+			if name == "id" {
+				value = record.RecordId
+			}
+			if !seen[value] {
+				seen[value] = true
+				values = append(values, value)
+			}
+		}
+		sort.Strings(values)
+		return values
+	}
+	return nil
+}
+
+// records returns the cached records of the domain of the command line, they
+// are queried if the cache is too old.
+func (cmd *CmdComplete) records(globals []string, args []string) *recordCache {
+	global := Options{}
+	flagSet := cmd.registry.flags(&global)
+	flagSet.SetOutput(io.Discard)
+	flagSet.Parse(globals)
+
+	// resolve the profile the way the command would, from its profile flags
+	var arguments []string
+	if global.Profile != "" {
+		arguments = append(arguments, "-profile="+global.Profile)
+	}
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		switch name {
+		case "domain", "profile", "key", "secret":
+			if !hasValue && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			arguments = append(arguments, "-"+name+"="+value)
+		}
+	}
+	profile := &Cmd{}
+	profile.init("__complete")
+	profile.flagSet.SetOutput(io.Discard)
+	if err := profile.Parse(arguments); err != nil || profile.DomainName == "" {
+		return nil
+	}
+
+	if cache := loadRecordCache(profile.DomainName); cache != nil {
+		return cache
+	}
+	if profile.AccessKeyId == "" || profile.AccessKeySecret == "" {
+		return nil
+	}
+	if global.Endpoint != "" {
+		utility.SetEndpoint(global.Endpoint)
+	}
+	api, err := profile.newApi()
+	if err != nil {
+		return nil
+	}
+	records, err := api.Query(&utility.QueryInfo{})
+	if err != nil {
+		return nil
+	}
+	return saveRecordCache(profile.DomainName, records)
+}
+
+func NewCmdComplete(registry *Registry) *CmdComplete {
+	cmd := CmdComplete{registry: registry}
+	if err := cmd.init(); err != nil {
+		panic(err)
+	} else {
+		return &cmd
+	}
+}
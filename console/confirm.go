@@ -0,0 +1,174 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+)
+
+// recordChange is a change of a record, shown to the user before it is made.
+// before is nil for a new record and after is nil for a deleted one.
+type recordChange struct {
+	before *utility.DomainRecord
+	after  *utility.DomainRecord
+}
+
+// confirmVar registers the flags of the commands asking for confirmation.
+func (cmd *Cmd) confirmVar() {
+	cmd.flagSet.BoolVar(&cmd.Yes, "yes", false, "make the changes without asking for confirmation, required when stdin is not a terminal")
+	cmd.forceVar()
+}
+
+func (cmd *Cmd) forceVar() {
+	cmd.flagSet.BoolVar(&cmd.Force, "force", false, "allow deleting, modifying or disabling the records of the protected RRs of the profile")
+}
+
+// isProtected tells whether the RR matches a protected RR of the profile.
+func (cmd *Cmd) isProtected(rr string) bool {
+	for _, pattern := range cmd.Protected {
+		if matched, _ := path.Match(pattern, rr); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkProtected refuses to delete or modify the records of the protected
+// RRs, or to move a record to one of them, unless -force is specified.
+func (cmd *Cmd) checkProtected(changes []*recordChange) error {
+	if cmd.Force {
+		return nil
+	}
+	for _, c := range changes {
+		switch {
+		case c.before == nil:
+			// a new record changes none of the existing ones
+			continue
+		case c.after == nil:
+			if err := cmd.checkProtectedRR(tea.StringValue(c.before.RR), "delete"); err != nil {
+				return err
+			}
+		default:
+			for _, rr := range []string{tea.StringValue(c.before.RR), tea.StringValue(c.after.RR)} {
+				if err := cmd.checkProtectedRR(rr, "modify"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkProtectedRR refuses to change the records of the RR with the verb, such
+// as "modify", if it is protected, unless -force is specified.
+func (cmd *Cmd) checkProtectedRR(rr string, verb string) error {
+	if !cmd.Force && cmd.isProtected(rr) {
+		return usageError{fmt.Errorf("the RR '%s' is protected by the profile, -force is required to %s its records", rr, verb)}
+	}
+	return nil
+}
+
+// confirmChanges shows the changes as a diff and asks the user to confirm
+// them. It returns ExitFailure if the user declines.
+func (cmd *Cmd) confirmChanges(prompt string, changes []*recordChange) error {
+	if err := cmd.checkProtected(changes); err != nil {
+		return err
+	}
+
+	printChanges(os.Stdout, changes)
+	return cmd.ask(prompt)
+}
+
+// ask asks the user to confirm the changes shown on the terminal, -yes
+// confirms them in advance. Nothing is asked in dry-run mode since nothing
+// is changed.
+func (cmd *Cmd) ask(prompt string) error {
+	if cmd.DryRun || cmd.Yes {
+		return nil
+	}
+	if !interactive() {
+		return usageError{errors.New("stdin is not a terminal, -yes is required to confirm the changes")}
+	}
+	if !confirm(prompt) {
+		fmt.Println("Cancelled, no domain name record was changed.")
+		return ExitFailure
+	}
+	return nil
+}
+
+// confirm asks the user to confirm on the terminal, any answer other than 'y'
+// or 'yes' declines.
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N]: ", prompt)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// interactive tells whether stdin is a terminal the user can answer from.
+func interactive() bool {
+	return isTerminal(int(os.Stdin.Fd()))
+}
+
+// printChanges prints the changes as a diff, the records deleted or replaced
+// prefixed with '-' and the new ones with '+'.
+func printChanges(w io.Writer, changes []*recordChange) {
+	for _, c := range changes {
+		switch {
+		case c.before == nil:
+			fmt.Fprintf(w, "Add a record:\n")
+			fmt.Fprintf(w, "  + %s\n", describeRecord(c.after))
+		case c.after == nil:
+			fmt.Fprintf(w, "Delete the record '%s':\n", tea.StringValue(c.before.RecordId))
+			fmt.Fprintf(w, "  - %s\n", describeRecord(c.before))
+		default:
+			fmt.Fprintf(w, "Modify the record '%s': %s\n", tea.StringValue(c.before.RecordId), strings.Join(diffRecords(c.before, c.after), ", "))
+			fmt.Fprintf(w, "  - %s\n", describeRecord(c.before))
+			fmt.Fprintf(w, "  + %s\n", describeRecord(c.after))
+		}
+	}
+}
+
+// describeRecord formats a record on a line of a diff.
+func describeRecord(record *utility.DomainRecord) string {
+	s := fmt.Sprintf("%s %s %s TTL=%d LINE=%s",
+		tea.StringValue(record.RR),
+		tea.StringValue(record.Type),
+		tea.StringValue(record.Value),
+		tea.Int64Value(record.TTL),
+		utility.LineValue(record.Line),
+	)
+	if record.Status != nil {
+		s += " STATUS=" + strings.ToUpper(*record.Status)
+	}
+	return s
+}
+
+// diffRecords lists the attributes that differ from a record to another, such
+// as "Value: 203.0.113.10 -> 203.0.113.11".
+func diffRecords(before *utility.DomainRecord, after *utility.DomainRecord) []string {
+	var diffs []string
+	diff := func(name string, was string, is string) {
+		if was != is {
+			diffs = append(diffs, fmt.Sprintf("%s: %s -> %s", name, was, is))
+		}
+	}
+	diff("RR", tea.StringValue(before.RR), tea.StringValue(after.RR))
+	diff("Type", tea.StringValue(before.Type), tea.StringValue(after.Type))
+	diff("Value", tea.StringValue(before.Value), tea.StringValue(after.Value))
+	diff("TTL", fmt.Sprint(tea.Int64Value(before.TTL)), fmt.Sprint(tea.Int64Value(after.TTL)))
+	diff("Line", utility.LineValue(before.Line), utility.LineValue(after.Line))
+	diff("Priority", fmt.Sprint(tea.Int64Value(before.Priority)), fmt.Sprint(tea.Int64Value(after.Priority)))
+	diff("Remark", tea.StringValue(before.Remark), tea.StringValue(after.Remark))
+	diff("Status", strings.ToUpper(tea.StringValue(before.Status)), strings.ToUpper(tea.StringValue(after.Status)))
+	if before.Weight != nil && after.Weight != nil {
+		diff("Weight", fmt.Sprint(*before.Weight), fmt.Sprint(*after.Weight))
+	}
+	return diffs
+}
@@ -1,44 +1,92 @@
 package console
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"os"
 	"time"
 
 	"github.com/alibabacloud-go/tea/tea"
 	"github.com/kdiot/alidns-console/ddns"
 	"github.com/kdiot/alidns-console/utility"
+	"github.com/olekukonko/tablewriter"
 )
 
+// ddnsActions are the subcommands of 'ddns', with the line of their usage.
+var ddnsActions = map[string]string{
+	"run":    "Update the domain name records when the IP addresses change, the default",
+	"status": "Show the status of a running daemon from its status API",
+	"serve":  "Update the records with the addresses submitted to a dyndns2 compatible server",
+}
+
+// CmdDdns is one of 'ddns run|status|serve'.
 type CmdDdns struct {
 	Cmd
-	RR            string
-	Type          string
-	TTL           int64
-	Network       string
-	ConfigFile    string
-	CheckInterval time.Duration
-	RetryInterval time.Duration
-	LogFile       string
-	LogLevel      utility.LogLevel
-	config        *ddns.Config
+	RR                string
+	Type              string
+	TTL               int64
+	Line              string
+	Network           string
+	ConfigFile        string
+	CheckInterval     time.Duration
+	RetryInterval     time.Duration
+	MaxRetryInterval  time.Duration
+	ReconcileInterval time.Duration
+	LogFile           string
+	StateFile         string
+	Listen            string
+	MetricsListen     string
+	Once              bool
+	Timeout           time.Duration
+	LogLevel          utility.LogLevel
+	action            string
+	config            *ddns.Config
 }
 
-func (cmd *CmdDdns) init() error {
-	if err := cmd.Cmd.init("ddns"); err != nil {
+func (cmd *CmdDdns) init(action string) error {
+
+	summary, ok := ddnsActions[action]
+	if !ok {
+		return fmt.Errorf("unknown ddns action '%s'", action)
+	}
+	if err := cmd.Cmd.init(action); err != nil {
 		return err
 	}
+	cmd.action = action
 
 	cmd.LogLevel = utility.LOG_INFO
 
+	usage := cmd.flagSet.Usage
+	cmd.flagSet.Usage = func() {
+		fmt.Printf("Usage:  alidns ddns %s [OPTIONS]\n", action)
+		fmt.Println(summary)
+		usage()
+	}
+
+	cmd.flagSet.StringVar(&cmd.ConfigFile, "conf", "", "config file name")
+	cmd.flagSet.StringVar(&cmd.Listen, "listen", "", "address of the status API, such as '127.0.0.1:8053' or 'unix:/run/aliddns.sock'")
+	if action == "status" {
+		// the status is queried from the running daemon
+		return nil
+	}
+
 	cmd.flagSet.StringVar(&cmd.RR, "rr", "@", "RR(Resource-Record)")
 	cmd.flagSet.StringVar(&cmd.Type, "type", "", "domain name record type, only 'A' or 'AAAA' can be selected.")
 	cmd.flagSet.Int64Var(&cmd.TTL, "ttl", 600, "TTL(Time-To-Live), Retention time of domain name records in DNS servers.")
+	cmd.flagSet.StringVar(&cmd.Line, "line", "", "resolution line of the domain name record, such as 'telecom', the default line if not specified")
 	cmd.flagSet.StringVar(&cmd.Network, "network", "", "network config, specify the local address and prefix length, IPv6 only")
-	cmd.flagSet.StringVar(&cmd.ConfigFile, "conf", "", "config file name")
-	cmd.flagSet.DurationVar(&cmd.CheckInterval, "chkIntvl", 10, "check whether the IP address has changed every X seconds")
-	cmd.flagSet.DurationVar(&cmd.RetryInterval, "retryIntvl", 30, "retry interval after update domain name record fails")
+	cmd.secondsVar(&cmd.CheckInterval, "chkIntvl", 10, "check whether the IP address has changed every X seconds")
+	cmd.secondsVar(&cmd.RetryInterval, "retryIntvl", 30, "retry interval after update domain name record fails, doubled after every failure")
+	cmd.secondsVar(&cmd.MaxRetryInterval, "maxRetryIntvl", 3600, "maximum retry interval after update domain name record fails")
+	cmd.secondsVar(&cmd.ReconcileInterval, "reconcileIntvl", 0, "check that the domain name records in DNS still hold the detected IP address every X seconds, 0 disables it")
 	cmd.flagSet.StringVar(&cmd.LogFile, "log", "", "log file")
+	cmd.flagSet.StringVar(&cmd.StateFile, "state", "", "state file, remembers the published records so that a restart does not update them again")
+	cmd.flagSet.StringVar(&cmd.MetricsListen, "metricsListen", "", "address that only exposes the Prometheus metrics, such as ':9153'")
+	cmd.flagSet.BoolVar(&cmd.Once, "once", false, "detect the IP addresses and update the domain name records once, then exit. "+
+		"The exit status is 0 if records were updated, 3 if no record needed to be updated, and 1 if any record failed")
+	cmd.secondsVar(&cmd.Timeout, "timeout", 60, "give up a one-shot update that does not finish in X seconds")
 	cmd.flagSet.Var(&cmd.LogLevel, "loglvl", "log level. LogLevel[debug,info,warning,error,fatal]")
 
 	return nil
@@ -55,23 +103,27 @@ func (cmd *CmdDdns) Parse(arguments []string) error {
 		return err
 	}
 
-	if cmd.ConfigFile != "" {
-		cmd.config, err = ddns.LoadConfig(cmd.ConfigFile)
-		if err != nil {
-			return fmt.Errorf("failed to load configuration file! [%s, %s]", cmd.ConfigFile, err.Error())
+	if cmd.action == "status" {
+		if cmd.Listen == "" && cmd.ConfigFile != "" {
+			if cmd.config, err = ddns.LoadConfig(cmd.ConfigFile); err != nil {
+				return fmt.Errorf("failed to load configuration file! [%s, %s]", cmd.ConfigFile, err.Error())
+			}
+			cmd.Listen = tea.StringValue(cmd.config.Listen)
 		}
-
-		if !cmd.config.LogLevel.IsValid() {
-			cmd.config.LogLevel = cmd.LogLevel
+		if cmd.Listen == "" {
+			return errors.New("the address of the status API must be specified by -listen or -conf")
 		}
+		return nil
+	}
 
-		cmd.config.LogFile = utility.DefaultIfEmpty(cmd.config.LogFile, &cmd.LogFile)
-		if tea.StringValue(cmd.config.AccessKeyId) == "" || tea.StringValue(cmd.config.AccessKeySecret) == "" {
-			cmd.config.AccessKeyId = &cmd.AccessKeyId
-			cmd.config.AccessKeySecret = &cmd.AccessKeySecret
-		}
-		cmd.config.DomainName = utility.DefaultIfEmpty(cmd.config.DomainName, &cmd.DomainName)
+	if cmd.action == "serve" && cmd.ConfigFile == "" {
+		return errors.New("the users of the dyndns2 update server must be configured by -conf")
+	}
 
+	if cmd.ConfigFile != "" {
+		if cmd.config, err = cmd.loadConfig(); err != nil {
+			return err
+		}
 	} else {
 		if cmd.RR == "" {
 			return errors.New("RR must be specified")
@@ -79,28 +131,28 @@ func (cmd *CmdDdns) Parse(arguments []string) error {
 			return errors.New("domain name record type must 'A' or 'AAAA'")
 		}
 		cmd.config = &ddns.Config{
-			AccessKeyId:     &cmd.AccessKeyId,
-			AccessKeySecret: &cmd.AccessKeySecret,
-			DomainName:      &cmd.DomainName,
-			CheckInterval:   cmd.CheckInterval,
-			RetryInterval:   cmd.RetryInterval,
+			AccessKeyId:       &cmd.AccessKeyId,
+			AccessKeySecret:   &cmd.AccessKeySecret,
+			DomainName:        &cmd.DomainName,
+			CheckInterval:     cmd.CheckInterval,
+			RetryInterval:     cmd.RetryInterval,
+			MaxRetryInterval:  cmd.MaxRetryInterval,
+			ReconcileInterval: cmd.ReconcileInterval,
+			StateFile:         &cmd.StateFile,
+			DryRun:            cmd.DryRun,
+			Listen:            &cmd.Listen,
+			MetricsListen:     &cmd.MetricsListen,
 			DomainList: []*ddns.DDNS{
 				{
 					RR:      &cmd.RR,
 					Type:    &cmd.Type,
 					Network: &cmd.Network,
+					Line:    &cmd.Line,
 					TTL:     &cmd.TTL,
 				},
 			},
 		}
-	}
-
-	for _, d := range cmd.config.DomainList {
-		if tea.StringValue(d.AccessKeyId) == "" || tea.StringValue(d.AccessKeySecret) == "" {
-			d.AccessKeyId = cmd.config.AccessKeyId
-			d.AccessKeySecret = cmd.config.AccessKeySecret
-		}
-		d.DomainName = utility.DefaultIfEmpty(d.DomainName, cmd.config.DomainName)
+		cmd.inherit(cmd.config)
 	}
 
 	if tea.StringValue(cmd.config.LogFile) != "" {
@@ -108,26 +160,209 @@ func (cmd *CmdDdns) Parse(arguments []string) error {
 			err = fmt.Errorf("failed to open log file!(%s, %s)", *cmd.config.LogFile, err.Error())
 			utility.Warning(err.Error())
 		}
+	} else {
+		// keep the priority and the fields of the messages when run by systemd
+		utility.UseJournal()
+	}
+	if options.Verbose {
+		cmd.config.LogLevel = utility.LOG_DEBUG
 	}
 	utility.SetLogLevel(cmd.config.LogLevel)
 
 	return nil
 }
 
+// loadConfig reads the configuration file, falling back to the command line
+// parameters for the settings it does not specify.
+func (cmd *CmdDdns) loadConfig() (*ddns.Config, error) {
+	config, err := ddns.LoadConfig(cmd.ConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration file! [%s, %s]", cmd.ConfigFile, err.Error())
+	}
+
+	if !config.LogLevel.IsValid() {
+		config.LogLevel = cmd.LogLevel
+	}
+
+	config.LogFile = utility.DefaultIfEmpty(config.LogFile, &cmd.LogFile)
+	config.StateFile = utility.DefaultIfEmpty(config.StateFile, &cmd.StateFile)
+	config.Listen = utility.DefaultIfEmpty(config.Listen, &cmd.Listen)
+	config.MetricsListen = utility.DefaultIfEmpty(config.MetricsListen, &cmd.MetricsListen)
+	if config.MaxRetryInterval == 0 {
+		config.MaxRetryInterval = cmd.MaxRetryInterval
+	}
+	if config.ReconcileInterval == 0 {
+		config.ReconcileInterval = cmd.ReconcileInterval
+	}
+	if tea.StringValue(config.AccessKeyId) == "" || tea.StringValue(config.AccessKeySecret) == "" {
+		config.AccessKeyId = &cmd.AccessKeyId
+		config.AccessKeySecret = &cmd.AccessKeySecret
+	}
+	config.DomainName = utility.DefaultIfEmpty(config.DomainName, &cmd.DomainName)
+	config.DryRun = config.DryRun || cmd.DryRun
+
+	cmd.inherit(config)
+	return config, nil
+}
+
+// reloadConfig is called by the daemon on SIGHUP.
+func (cmd *CmdDdns) reloadConfig() (*ddns.Config, error) {
+	config, err := cmd.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if options.Verbose {
+		config.LogLevel = utility.LOG_DEBUG
+	}
+	utility.SetLogLevel(config.LogLevel)
+	return config, nil
+}
+
+// inherit fills in the credentials and domain name of the DDNS entries that
+// do not specify their own.
+func (cmd *CmdDdns) inherit(config *ddns.Config) {
+	for _, d := range config.DomainList {
+		if tea.StringValue(d.AccessKeyId) == "" || tea.StringValue(d.AccessKeySecret) == "" {
+			d.AccessKeyId = config.AccessKeyId
+			d.AccessKeySecret = config.AccessKeySecret
+		}
+		d.DomainName = utility.DefaultIfEmpty(d.DomainName, config.DomainName)
+	}
+}
+
 func (cmd *CmdDdns) Execute() error {
 
+	if cmd.action == "status" {
+		return cmd.status()
+	}
+
+	if cmd.Once {
+		return cmd.once()
+	}
+
+	if cmd.action == "serve" {
+		daemon, err := ddns.NewDaemon(cmd.config)
+		if err != nil {
+			return err
+		}
+		daemon.SetConfigLoader(cmd.reloadConfig)
+		return daemon.Serve()
+	}
+
 	daemon, err := ddns.NewDaemon(cmd.config)
 	if err != nil {
 		return err
 	}
+	if cmd.ConfigFile != "" {
+		daemon.SetConfigLoader(cmd.reloadConfig)
+	}
 	daemon.Run()
 
 	return nil
 }
 
-func NewCmdDdns() *CmdDdns {
+// once runs the daemon for a single check, for use from cron or a systemd timer.
+func (cmd *CmdDdns) once() error {
+
+	daemon, err := ddns.NewDaemon(cmd.config)
+	if err != nil {
+		return err
+	}
+	results := daemon.RunOnce(cmd.Timeout * time.Second)
+
+	updated, failed := 0, 0
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"RECORD", "VALUE", "RESULT", "ERROR"})
+	for _, result := range results {
+		switch result.Result {
+		case ddns.SYNC_UPDATED:
+			updated++
+		case ddns.SYNC_FAILED, ddns.SYNC_TIMEOUT:
+			failed++
+		}
+		table.Append([]string{result.Key, result.Value, result.Result.String(), result.Error})
+	}
+	table.Render()
+
+	if failed > 0 || len(results) == 0 {
+		return ExitFailure
+	} else if updated == 0 {
+		return ExitUnchanged
+	}
+	return nil
+}
+
+// status queries the status API of a running daemon.
+func (cmd *CmdDdns) status() error {
+
+	client, baseURL := ddns.NewClient(cmd.Listen)
+	response, err := client.Get(baseURL + "/v1/status")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("the status API responded with '%s'", response.Status)
+	}
+
+	status := &ddns.DaemonStatus{}
+	if err = json.NewDecoder(response.Body).Decode(status); err != nil {
+		return err
+	}
+
+	if jsonOutput() {
+		return printJSON(status)
+	}
+
+	formatTime := func(t *time.Time) string {
+		if t == nil || t.IsZero() {
+			return "-"
+		}
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"TYPE", "IP", "SOURCE", "RESULT", "CHECKED"})
+	for _, address := range status.Addresses {
+		if len(address.Sources) == 0 {
+			table.Append([]string{address.Type, address.IP, "-", "-", "-"})
+		}
+		for _, source := range address.Sources {
+			result := source.IP
+			if source.Error != "" {
+				result = source.Error
+			}
+			table.Append([]string{address.Type, address.IP, source.Source, result, formatTime(&source.Checked)})
+		}
+	}
+	table.Render()
+
+	table = tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"RECORD", "VALUE", "LAST SUCCESS", "LAST ERROR", "NEXT RETRY"})
+	for _, record := range status.Records {
+		lastError := "-"
+		if record.LastError != "" {
+			lastError = fmt.Sprintf("%s (%s)", record.LastError, formatTime(record.LastFailure))
+		}
+		table.Append([]string{
+			record.Key,
+			record.Value,
+			formatTime(record.LastSuccess),
+			lastError,
+			formatTime(record.NextRetry),
+		})
+	}
+	table.Render()
+
+	return nil
+}
+
+// NewCmdDdns creates the subcommand of 'ddns' of the action: run, status or
+// serve.
+func NewCmdDdns(action string) *CmdDdns {
 	cmd := CmdDdns{}
-	if err := cmd.init(); err != nil {
+	if err := cmd.init(action); err != nil {
 		panic(err)
 	} else {
 		return &cmd
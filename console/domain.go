@@ -0,0 +1,89 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/olekukonko/tablewriter"
+)
+
+// CmdDomain lists the domains of the account: 'domain ls'.
+type CmdDomain struct {
+	Cmd
+	KeyWord string
+}
+
+func (cmd *CmdDomain) init() error {
+
+	if err := cmd.Cmd.init("ls"); err != nil {
+		return err
+	}
+
+	cmd.flagSet.StringVar(&cmd.KeyWord, "keyword", "", "only list the domains containing the keyword")
+
+	usage := cmd.flagSet.Usage
+	cmd.flagSet.Usage = func() {
+		fmt.Println("Usage:  alidns domain ls [OPTIONS]")
+		fmt.Println("List the domains of the account, -domain is not needed")
+		usage()
+	}
+
+	return nil
+}
+
+func (cmd *CmdDomain) Check() error {
+
+	// the domains are those of the account, whatever the domain of the profile
+	if cmd.AccessKeyId == "" {
+		return authError{errors.New("access key id is not specified")}
+	}
+
+	if cmd.AccessKeySecret == "" {
+		return authError{errors.New("access key secret is not specified")}
+	}
+
+	return nil
+}
+
+func (cmd *CmdDomain) Execute() error {
+
+	api, err := cmd.newApi()
+	if err != nil {
+		return err
+	}
+
+	domains, err := api.Domains(cmd.KeyWord)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput() {
+		return printJSON(domains)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"DOMAIN", "RECORDS", "VERSION", "GROUP", "REMARK"})
+	for _, domain := range domains {
+		table.Append([]string{
+			tea.StringValue(domain.DomainName),
+			fmt.Sprintf("%d", tea.Int64Value(domain.RecordCount)),
+			tea.StringValue(domain.VersionName),
+			tea.StringValue(domain.GroupName),
+			tea.StringValue(domain.Remark),
+		})
+	}
+	table.Render()
+
+	return nil
+}
+
+func NewCmdDomain() *CmdDomain {
+	cmd := CmdDomain{}
+	if err := cmd.init(); err != nil {
+		panic(err)
+	} else {
+		return &cmd
+	}
+}
@@ -0,0 +1,159 @@
+package console
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+)
+
+// CmdExport writes the records as the add operations of a batch, so that they
+// can be created again with 'record batch', on this domain or on another one.
+type CmdExport struct {
+	Cmd
+	RR        string
+	Type      string
+	SubDomain string
+	Format    string
+	File      string
+}
+
+func (cmd *CmdExport) init() error {
+
+	if err := cmd.Cmd.init("export"); err != nil {
+		return err
+	}
+
+	cmd.flagSet.StringVar(&cmd.RR, "rr", "", "only export the records of this resource record")
+	cmd.flagSet.StringVar(&cmd.Type, "type", "", "only export the records of this type")
+	cmd.flagSet.StringVar(&cmd.SubDomain, "subdomain", "", "export the records of the subdomain of this RR, '*.staging' exports every subdomain under 'staging'")
+	cmd.flagSet.StringVar(&cmd.Format, "format", "json", "format of the operations, 'json' (one object per line) or 'csv' (with a header line)")
+	cmd.flagSet.StringVar(&cmd.File, "file", "-", "file the operations are written to, '-' writes them to stdout")
+
+	usage := cmd.flagSet.Usage
+	cmd.flagSet.Usage = func() {
+		fmt.Println("Usage:  alidns record export [OPTIONS]")
+		fmt.Println("Writes the records as add operations, which 'alidns record batch' applies:")
+		fmt.Println("  alidns record export -subdomain '*.staging' > staging.json")
+		fmt.Println("  alidns record batch -file staging.json")
+		usage()
+	}
+
+	return nil
+}
+
+func (cmd *CmdExport) Check() error {
+
+	if err := cmd.Cmd.Check(); err != nil {
+		return err
+	}
+
+	if cmd.SubDomain != "" && cmd.RR != "" {
+		return errors.New("-rr and -subdomain cannot be specified together")
+	}
+
+	if cmd.Format != "json" && cmd.Format != "csv" {
+		return fmt.Errorf("unknown format '%s', it must be 'json' or 'csv'", cmd.Format)
+	}
+
+	return nil
+}
+
+func (cmd *CmdExport) Execute() error {
+
+	api, err := cmd.newApi()
+	if err != nil {
+		return err
+	}
+
+	var records []*utility.DomainRecord
+	if cmd.SubDomain != "" {
+		records, err = api.SubDomainRecords(cmd.SubDomain, cmd.Type)
+	} else {
+		query := &utility.QueryInfo{}
+		if cmd.RR != "" {
+			query.RR = &cmd.RR
+		}
+		if cmd.Type != "" {
+			query.Type = &cmd.Type
+		}
+		records, err = api.Query(query)
+	}
+	if err != nil {
+		return err
+	}
+
+	var output io.Writer = os.Stdout
+	if cmd.File != "-" {
+		file, err := os.OpenFile(cmd.File, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+
+	operations := make([]*Operation, 0, len(records))
+	for _, record := range records {
+		operations = append(operations, &Operation{
+			Op:       "add",
+			RecordId: tea.StringValue(record.RecordId),
+			RR:       tea.StringValue(record.RR),
+			Type:     tea.StringValue(record.Type),
+			Value:    tea.StringValue(record.Value),
+			TTL:      tea.Int64Value(record.TTL),
+			Line:     utility.LineValue(record.Line),
+		})
+	}
+	if cmd.Format == "csv" {
+		err = writeCSVOperations(output, operations)
+	} else {
+		err = writeJSONOperations(output, operations)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cmd.File != "-" {
+		fmt.Printf("The %d domain name records were exported to '%s'.\n", len(operations), cmd.File)
+	}
+	return nil
+}
+
+// writeJSONOperations writes a JSON object per line, as readJSONOperations reads them.
+func writeJSONOperations(w io.Writer, operations []*Operation) error {
+	encoder := json.NewEncoder(w)
+	for _, op := range operations {
+		if err := encoder.Encode(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCSVOperations writes the operations with a header line, as
+// readCSVOperations reads them.
+func writeCSVOperations(w io.Writer, operations []*Operation) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"op", "id", "rr", "type", "value", "ttl", "line"})
+	for _, op := range operations {
+		writer.Write([]string{op.Op, op.RecordId, op.RR, op.Type, op.Value, strconv.FormatInt(op.TTL, 10), op.Line})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func NewCmdExport() *CmdExport {
+	cmd := CmdExport{}
+	if err := cmd.init(); err != nil {
+		panic(err)
+	} else {
+		return &cmd
+	}
+}
@@ -0,0 +1,314 @@
+package console
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// stdin is shared by the prompts, so that no input is lost in the buffer of
+// another reader.
+var stdin = bufio.NewReader(os.Stdin)
+
+// completer returns the candidates completing the word ending at pos in the
+// line, and the position the word starts at.
+type completer func(line string, pos int) (int, []string)
+
+// lineEditor reads the lines typed at an interactive prompt with the usual
+// editing keys, a history and tab completion. It reads plain lines if the
+// input is not a terminal.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	terminal bool
+	history  []string
+	complete completer
+}
+
+func newLineEditor(complete completer) *lineEditor {
+	fd := int(os.Stdin.Fd())
+	return &lineEditor{
+		in:       stdin,
+		out:      os.Stdout,
+		fd:       fd,
+		terminal: isTerminal(fd),
+		complete: complete,
+	}
+}
+
+// addHistory appends a line to the history, unless it repeats the last one.
+func (e *lineEditor) addHistory(line string) bool {
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return false
+	}
+	e.history = append(e.history, line)
+	return true
+}
+
+// readLine prints the prompt and returns the line typed, io.EOF at the end of
+// the input or when Ctrl-D is typed on an empty line.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	if !e.terminal {
+		return e.readPlain()
+	}
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		fmt.Fprint(e.out, prompt)
+		return e.readPlain()
+	}
+	defer restore()
+
+	var buf []rune
+	pos, index, saved, tabs := 0, len(e.history), "", 0
+
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if tail := runewidth.StringWidth(string(buf[pos:])); tail > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", tail)
+		}
+	}
+	set := func(line string) {
+		buf = []rune(line)
+		pos = len(buf)
+	}
+	insert := func(s string) {
+		r := []rune(s)
+		buf = append(buf[:pos], append(r, buf[pos:]...)...)
+		pos += len(r)
+	}
+	browse := func(to int) {
+		if to < 0 || to > len(e.history) || to == index {
+			return
+		}
+		if index == len(e.history) {
+			saved = string(buf)
+		}
+		index = to
+		if index == len(e.history) {
+			set(saved)
+		} else {
+			set(e.history[index])
+		}
+	}
+
+	refresh()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			fmt.Fprintln(e.out)
+			if err == io.EOF && len(buf) > 0 {
+				return string(buf), nil
+			}
+			return "", err
+		}
+		if r == '\t' {
+			tabs++
+		} else {
+			tabs = 0
+		}
+
+		switch r {
+		case '\r', '\n':
+			pos = len(buf)
+			refresh()
+			fmt.Fprintln(e.out)
+			return string(buf), nil
+		case 3: // Ctrl-C cancels the line
+			fmt.Fprintln(e.out, "^C")
+			buf, pos = nil, 0
+			index = len(e.history)
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Fprintln(e.out)
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(buf)
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl-F
+			if pos < len(buf) {
+				pos++
+			}
+		case 11: // Ctrl-K
+			buf = buf[:pos]
+		case 21: // Ctrl-U
+			buf = buf[pos:]
+			pos = 0
+		case 23: // Ctrl-W deletes the word before the cursor
+			start := pos
+			for start > 0 && buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && buf[start-1] != ' ' {
+				start--
+			}
+			buf = append(buf[:start], buf[pos:]...)
+			pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			browse(index - 1)
+		case 14: // Ctrl-N
+			browse(index + 1)
+		case '\t':
+			e.completeWord(&buf, &pos, tabs, prompt)
+		case 27:
+			e.escape(&buf, &pos, browse, index)
+		default:
+			if r >= 32 {
+				insert(string(r))
+			}
+		}
+		refresh()
+	}
+}
+
+// escape handles the escape sequences of the arrow, Home, End and Delete keys.
+func (e *lineEditor) escape(buf *[]rune, pos *int, browse func(int), index int) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return
+	}
+	var param []rune
+	for {
+		if r, _, err = e.in.ReadRune(); err != nil {
+			return
+		}
+		if r < '0' || r > '9' {
+			break
+		}
+		param = append(param, r)
+	}
+
+	switch {
+	case r == 'A':
+		browse(index - 1)
+	case r == 'B':
+		browse(index + 1)
+	case r == 'C':
+		if *pos < len(*buf) {
+			*pos++
+		}
+	case r == 'D':
+		if *pos > 0 {
+			*pos--
+		}
+	case r == 'H', r == '~' && (string(param) == "1" || string(param) == "7"):
+		*pos = 0
+	case r == 'F', r == '~' && (string(param) == "4" || string(param) == "8"):
+		*pos = len(*buf)
+	case r == '~' && string(param) == "3":
+		if *pos < len(*buf) {
+			*buf = append((*buf)[:*pos], (*buf)[*pos+1:]...)
+		}
+	}
+}
+
+// completeWord completes the word before the cursor with the common prefix of
+// the candidates, and lists them when Tab is typed twice.
+func (e *lineEditor) completeWord(buf *[]rune, pos *int, tabs int, prompt string) {
+	if e.complete == nil {
+		return
+	}
+	line := string((*buf)[:*pos])
+	start, candidates := e.complete(line, len(line))
+	if len(candidates) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+	start = len([]rune(line[:start]))
+	word := string((*buf)[start:*pos])
+
+	completion := candidates[0]
+	if len(candidates) == 1 {
+		completion += " "
+	} else {
+		for _, candidate := range candidates[1:] {
+			for !strings.HasPrefix(candidate, completion) {
+				completion = completion[:len(completion)-1]
+			}
+		}
+	}
+
+	if len(completion) > len(word) {
+		tail := []rune(completion)
+		*buf = append((*buf)[:start], append(tail, (*buf)[*pos:]...)...)
+		*pos = start + len(tail)
+		return
+	}
+
+	if tabs > 1 {
+		sort.Strings(candidates)
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	} else {
+		fmt.Fprint(e.out, "\a")
+	}
+}
+
+func (e *lineEditor) readPlain() (string, error) {
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// splitArgs splits a command line into arguments the way a shell does, with
+// single quotes, double quotes and backslash escapes.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg, quote, escaped := false, rune(0), false
+	for _, r := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in '%s'", line)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,77 @@
+package console
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kdiot/alidns-console/utility"
+	"github.com/olekukonko/tablewriter"
+)
+
+// CmdLines lists the resolution lines the records can use: 'lines ls'.
+type CmdLines struct {
+	Cmd
+	Custom bool
+}
+
+func (cmd *CmdLines) init() error {
+
+	if err := cmd.Cmd.init("ls"); err != nil {
+		return err
+	}
+
+	cmd.flagSet.BoolVar(&cmd.Custom, "custom", false, "only list the custom lines of the domain")
+
+	usage := cmd.flagSet.Usage
+	cmd.flagSet.Usage = func() {
+		fmt.Println("Usage:  alidns lines ls [OPTIONS]")
+		fmt.Println("List the resolution lines, the CODE is the value of -line")
+		usage()
+	}
+
+	return nil
+}
+
+func (cmd *CmdLines) Execute() error {
+
+	api, err := cmd.newApi()
+	if err != nil {
+		return err
+	}
+
+	var lines []*utility.Line
+	if cmd.Custom {
+		lines, err = api.CustomLines()
+	} else {
+		lines, err = api.Lines()
+	}
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput() {
+		return printJSON(lines)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"CODE", "NAME", "PARENT", "CUSTOM"})
+	for _, line := range lines {
+		custom := ""
+		if line.Custom {
+			custom = "yes"
+		}
+		table.Append([]string{line.Code, line.Name, line.Father, custom})
+	}
+	table.Render()
+
+	return nil
+}
+
+func NewCmdLines() *CmdLines {
+	cmd := CmdLines{}
+	if err := cmd.init(); err != nil {
+		panic(err)
+	} else {
+		return &cmd
+	}
+}
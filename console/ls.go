@@ -1,19 +1,24 @@
 package console
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"github.com/alibabacloud-go/tea/tea"
 	"github.com/kdiot/alidns-console/utility"
 	"github.com/olekukonko/tablewriter"
 )
 
 type CmdLs struct {
 	Cmd
-	RR     string
-	Type   string
-	Line   string
-	Status string
+	RR        string
+	Type      string
+	Line      string
+	Status    string
+	SubDomain string
 }
 
 func (cmd *CmdLs) init() error {
@@ -26,6 +31,7 @@ func (cmd *CmdLs) init() error {
 	cmd.flagSet.StringVar(&cmd.Type, "type", "", "domain name record type, A|AAAA|CNAME|TXT")
 	cmd.flagSet.StringVar(&cmd.Line, "line", "", "Line")
 	cmd.flagSet.StringVar(&cmd.Status, "status", "", "status")
+	cmd.flagSet.StringVar(&cmd.SubDomain, "subdomain", "", "list the records of the subdomain of this RR, '*.staging' lists every subdomain under 'staging'")
 
 	return nil
 }
@@ -35,6 +41,11 @@ func (cmd *CmdLs) Check() error {
 	if err := cmd.Cmd.Check(); err != nil {
 		return err
 	}
+
+	if cmd.SubDomain != "" && cmd.RR != "" {
+		return errors.New("-rr and -subdomain cannot be specified together")
+	}
+
 	return nil
 }
 
@@ -54,18 +65,55 @@ func (cmd *CmdLs) Execute() error {
 		query.Status = &cmd.Status
 	}
 
-	api, err := utility.NewAlidnsApi(cmd.DomainName, cmd.AccessKeyId, cmd.AccessKeySecret)
+	api, err := cmd.newApi()
 	if err != nil {
 		return err
 	}
 
-	records, err := api.Query(query)
+	var records []*utility.DomainRecord
+	if cmd.SubDomain != "" {
+		if records, err = api.SubDomainRecords(cmd.SubDomain, cmd.Type); err == nil {
+			records = filterRecords(records, cmd.Line, cmd.Status)
+		}
+	} else {
+		records, err = api.Query(query)
+	}
 	if err != nil {
 		return err
 	}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"ID", "RR", "TYPE", "VALUE", "TTL", "STATUS"})
+	// a complete listing refreshes the records cached for the completion
+	if cmd.SubDomain == "" && *query == (utility.QueryInfo{}) {
+		saveRecordCache(cmd.DomainName, records)
+	}
+
+	if jsonOutput() {
+		return printJSON(records)
+	}
+	printRecords(os.Stdout, records)
+
+	return nil
+}
+
+// filterRecords keeps the records of the line and of the status, for the
+// queries that cannot filter them.
+func filterRecords(records []*utility.DomainRecord, line string, status string) []*utility.DomainRecord {
+	var result []*utility.DomainRecord
+	for _, record := range records {
+		if line != "" && utility.LineValue(record.Line) != line {
+			continue
+		}
+		if status != "" && !strings.EqualFold(tea.StringValue(record.Status), status) {
+			continue
+		}
+		result = append(result, record)
+	}
+	return result
+}
+
+func printRecords(w io.Writer, records []*utility.DomainRecord) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"ID", "RR", "TYPE", "VALUE", "TTL", "LINE", "WEIGHT", "STATUS"})
 	for _, record := range records {
 		table.Append([]string{
 			*record.RecordId,
@@ -73,12 +121,12 @@ func (cmd *CmdLs) Execute() error {
 			*record.Type,
 			*record.Value,
 			fmt.Sprintf("%d", *record.TTL),
+			utility.LineValue(record.Line),
+			weight(record.Weight),
 			*record.Status,
 		})
 	}
 	table.Render()
-
-	return nil
 }
 
 func NewCmdLs() *CmdLs {
@@ -14,6 +14,7 @@ type CmdMod struct {
 	Type     string
 	Value    string
 	TTL      int64
+	Line     string
 }
 
 func (cmd *CmdMod) init() error {
@@ -27,6 +28,8 @@ func (cmd *CmdMod) init() error {
 	cmd.flagSet.StringVar(&cmd.Type, "type", "", "domain name record type")
 	cmd.flagSet.StringVar(&cmd.Value, "value", "", "value of domain name record")
 	cmd.flagSet.Int64Var(&cmd.TTL, "ttl", 0, "TTL(Time-To-Live), Retention time of domain name records in DNS servers.")
+	cmd.flagSet.StringVar(&cmd.Line, "line", "", "resolution line, such as 'telecom', see 'alidns lines ls'")
+	cmd.confirmVar()
 
 	return nil
 }
@@ -46,7 +49,7 @@ func (cmd *CmdMod) Check() error {
 
 func (cmd *CmdMod) Execute() error {
 
-	api, err := utility.NewAlidnsApi(cmd.DomainName, cmd.AccessKeyId, cmd.AccessKeySecret)
+	api, err := cmd.newApi()
 	if err != nil {
 		return err
 	}
@@ -55,6 +58,7 @@ func (cmd *CmdMod) Execute() error {
 	if err != nil {
 		return err
 	}
+	before := *record
 
 	if cmd.RR != "" {
 		record.RR = &cmd.RR
@@ -75,10 +79,23 @@ func (cmd *CmdMod) Execute() error {
 		record.TTL = &cmd.TTL
 	}
 
+	if cmd.Line != "" {
+		record.Line = &cmd.Line
+	}
+
+	if len(diffRecords(&before, record)) == 0 {
+		fmt.Printf("The domain name record with ID '%s' already has these values.\n", cmd.RecordId)
+		return nil
+	}
+	if err := cmd.confirmChanges("Modify this domain name record?", []*recordChange{{before: &before, after: record}}); err != nil {
+		return err
+	}
+
 	if err = api.Update(record); err != nil {
 		return err
 	}
 
+	cmd.dryRunNotice()
 	fmt.Printf(
 		"Domain name record successfully updated!\n"+
 			"ID:     %s\n"+
@@ -87,6 +104,7 @@ func (cmd *CmdMod) Execute() error {
 			"TYPE:   %s\n"+
 			"VALUE:  %s\n"+
 			"TTL:    %d\n"+
+			"LINE:   %s\n"+
 			"STATUS: %s\n",
 		*record.RecordId,
 		*record.DomainName,
@@ -94,6 +112,7 @@ func (cmd *CmdMod) Execute() error {
 		*record.Type,
 		*record.Value,
 		*record.TTL,
+		utility.LineValue(record.Line),
 		*record.Status,
 	)
 
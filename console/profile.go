@@ -4,12 +4,23 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
+// profileDir holds the named profiles, '-profile work' reads ~/.alidns.d/work.json.
+const profileDir = ".alidns.d"
+
 type Profile struct {
 	AccessKeyId     string `json:"AccessKeyId"`
 	AccessKeySecret string `json:"AccessKeySecret"`
 	DomainName      string `json:"DomainName"`
+	// Protected are the RRs whose records are only deleted with -force, such
+	// as "@" or "mail". They are patterns, "*.prod" protects every subdomain
+	// of 'prod'.
+	Protected []string `json:"Protected"`
 }
 
 func (profile *Profile) Load(fileName string) error {
@@ -30,3 +41,33 @@ func (profile *Profile) Load(fileName string) error {
 
 	return nil
 }
+
+// profilePath returns the file of a profile, given as a file name or as the
+// name of a profile in ~/.alidns.d.
+func profilePath(name string) string {
+	if _, err := os.Stat(name); err == nil || strings.ContainsRune(name, os.PathSeparator) {
+		return name
+	}
+	if user, err := user.Current(); err == nil {
+		fileName := filepath.Join(user.HomeDir, profileDir, name+".json")
+		if _, err := os.Stat(fileName); err == nil {
+			return fileName
+		}
+	}
+	return name
+}
+
+// profileNames lists the names of the profiles in ~/.alidns.d.
+func profileNames() []string {
+	user, err := user.Current()
+	if err != nil {
+		return nil
+	}
+	files, _ := filepath.Glob(filepath.Join(user.HomeDir, profileDir, "*.json"))
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(file), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,274 @@
+package console
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+)
+
+// Registry is the tree of the commands of the program, a command can be a
+// group of subcommands such as 'record ls'.
+type Registry struct {
+	// path is the names of the group, empty for the program itself
+	path    string
+	version string
+	entries []*entry
+	// fallback is the subcommand run when the group is given flags only
+	fallback string
+}
+
+type entry struct {
+	name string
+	// summary is the line of the usage, the entry is hidden if it is empty
+	summary string
+	command Command
+	group   *Registry
+	// create makes a new command for every line of the shell, it is nil for
+	// the commands the shell does not run
+	create func() Command
+}
+
+func NewRegistry(version string) *Registry {
+	return &Registry{version: version}
+}
+
+// Add registers a command under its name, it is hidden from the usage and
+// from the completion if the summary is empty.
+func (r *Registry) Add(cmd Command, summary string) {
+	r.entries = append(r.entries, &entry{name: cmd.Name(), summary: summary, command: cmd})
+}
+
+// AddFunc registers the command made by create, the shell runs it too. The
+// shell makes a new command for every line since the flags keep their values.
+func (r *Registry) AddFunc(create func() Command, summary string) {
+	cmd := create()
+	r.entries = append(r.entries, &entry{name: cmd.Name(), summary: summary, command: cmd, create: create})
+}
+
+// Group registers a group of subcommands, such as 'record' for 'record ls'.
+func (r *Registry) Group(name string, summary string) *Registry {
+	group := &Registry{path: strings.TrimSpace(r.path + " " + name), version: r.version}
+	r.entries = append(r.entries, &entry{name: name, summary: summary, group: group})
+	return group
+}
+
+// Default makes the group run the subcommand when it is only given flags, such
+// as 'ddns -conf FILE' for 'ddns run -conf FILE'.
+func (r *Registry) Default(name string) {
+	r.fallback = name
+}
+
+func (r *Registry) lookup(name string) *entry {
+	for _, e := range r.entries {
+		if e.name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// names returns the names of the entries shown in the usage.
+func (r *Registry) names() []string {
+	var names []string
+	for _, e := range r.entries {
+		if e.summary != "" {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+// flags returns the flags of the global options, given before the command.
+func (r *Registry) flags(o *Options) *flag.FlagSet {
+	flagSet := flag.NewFlagSet("alidns", flag.ContinueOnError)
+	flagSet.StringVar(&o.Profile, "profile", "", "profile of the commands that do not specify one, a file or the name of a profile in ~/.alidns.d")
+	flagSet.StringVar(&o.Output, "output", "text", "format of the listings, 'text' or 'json'")
+	flagSet.StringVar(&o.Endpoint, "endpoint", "", "endpoint of the Alidns API, such as 'alidns.cn-shanghai.aliyuncs.com'")
+	flagSet.BoolVar(&o.Verbose, "verbose", false, "log the requests sent to Alidns")
+	flagSet.Usage = func() {
+		r.usage(flagSet.Output())
+	}
+	return flagSet
+}
+
+func (r *Registry) usage(w io.Writer) {
+	if r.path == "" {
+		fmt.Fprintln(w, "Usage:  alidns [OPTIONS] COMMAND [ARGS]")
+	} else {
+		fmt.Fprintf(w, "Usage:  alidns %s COMMAND [ARGS]\n", r.path)
+	}
+	fmt.Fprintln(w, "Commands:")
+	for _, e := range r.entries {
+		if e.summary != "" {
+			fmt.Fprintf(w, "  %-11s %s\n", e.name, e.summary)
+		}
+	}
+	if r.path != "" {
+		return
+	}
+	fmt.Fprintln(w, "  help        Print help information for specific commands, such as: record ls, ddns run, etc.")
+	fmt.Fprintln(w, "  version     Show the alidns version information")
+
+	fmt.Fprintln(w, "Options:")
+	flagSet := r.flags(&Options{})
+	flagSet.SetOutput(w)
+	flagSet.PrintDefaults()
+
+	fmt.Fprintln(w, "Exit status:")
+	fmt.Fprintf(w, "  %d  the command succeeded\n", 0)
+	fmt.Fprintf(w, "  %d  the command failed, or a confirmation was declined\n", ExitFailure)
+	fmt.Fprintf(w, "  %d  the command line is wrong\n", ExitUsage)
+	fmt.Fprintf(w, "  %d  'ddns -once' found no record to update\n", ExitUnchanged)
+	fmt.Fprintf(w, "  %d  the credentials are missing, or refused by Alidns\n", ExitAuth)
+	fmt.Fprintf(w, "  %d  the domain, the record or a file does not exist\n", ExitNotFound)
+	fmt.Fprintf(w, "  %d  any other error of the Alidns API\n", ExitApi)
+}
+
+// Run parses the global options and runs the command of the arguments, it
+// returns the exit status of the program.
+func (r *Registry) Run(arguments []string) int {
+
+	flagSet := r.flags(&options)
+	if err := flagSet.Parse(arguments); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return int(ExitUsage)
+	}
+	if options.Output != "text" && options.Output != "json" {
+		fmt.Fprintf(os.Stderr, "Error! Unknown output format '%s', it must be 'text' or 'json'.\n", options.Output)
+		return int(ExitUsage)
+	}
+	if options.Endpoint != "" {
+		utility.SetEndpoint(options.Endpoint)
+	}
+	if options.Verbose {
+		utility.SetLogLevel(utility.LOG_DEBUG)
+	}
+	if jsonOutput() {
+		// the messages would break the JSON printed on stdout
+		utility.SetLogOutput(os.Stderr)
+	}
+
+	args := flagSet.Args()
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Error! Not enough command line arguments.")
+		r.usage(os.Stderr)
+		return int(ExitUsage)
+	}
+
+	switch args[0] {
+	case "help":
+		return r.help(args[1:])
+	case "version":
+		fmt.Println(r.version)
+		return 0
+	}
+	return r.run(args)
+}
+
+// help prints the usage of a command, or of a group and its subcommands.
+func (r *Registry) help(args []string) int {
+	if len(args) == 0 {
+		r.usage(os.Stdout)
+		return 0
+	}
+
+	e := r.lookup(args[0])
+	if e == nil {
+		fmt.Fprintf(os.Stderr, "Error! Could not find help for command '%s'.\n", strings.TrimSpace(r.path+" "+args[0]))
+		r.usage(os.Stderr)
+		return int(ExitUsage)
+	}
+	if e.group != nil {
+		return e.group.help(args[1:])
+	}
+	// the usage was asked for, it is not an error
+	if f, ok := e.command.(interface{ flags() *flag.FlagSet }); ok {
+		f.flags().SetOutput(os.Stdout)
+	}
+	e.command.Usage()
+	return 0
+}
+
+// isHelpFlag tells whether the argument asks for the usage.
+func isHelpFlag(arg string) bool {
+	switch arg {
+	case "-h", "-help", "--help":
+		return true
+	}
+	return false
+}
+
+// run runs the command named by the first argument, looking up the
+// subcommands of the groups.
+func (r *Registry) run(args []string) int {
+
+	name := strings.TrimSpace(r.path + " " + args[0])
+	e := r.lookup(args[0])
+	if e == nil {
+		fmt.Fprintf(os.Stderr, "Error! Unknown command '%s', run 'alidns help' for the commands.\n", name)
+		return int(ExitUsage)
+	}
+
+	if e.group != nil {
+		if fallback := e.group.fallback; fallback != "" && (len(args) < 2 || strings.HasPrefix(args[1], "-") && !isHelpFlag(args[1])) {
+			return e.group.run(append([]string{fallback}, args[1:]...))
+		}
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "Error! The command '%s' needs a subcommand.\n", name)
+			e.group.usage(os.Stderr)
+			return int(ExitUsage)
+		}
+		if isHelpFlag(args[1]) {
+			e.group.usage(os.Stdout)
+			return 0
+		}
+		return e.group.run(args[1:])
+	}
+
+	cmd := e.command
+	if err := cmd.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		// the flag package has reported the errors of the flags
+		if _, ok := err.(flagError); !ok {
+			fmt.Fprintf(os.Stderr, "Illegal parameter of command '%s': %s\n", name, err.Error())
+		}
+		return int(ExitUsage)
+	}
+
+	if err := cmd.Check(); err != nil {
+		fmt.Fprintf(os.Stderr, "Command line '%s' parameter check failed: %s\n", name, err.Error())
+		if code := exitCodeOf(err); code == ExitAuth {
+			return int(code)
+		}
+		return int(ExitUsage)
+	}
+
+	if err := cmd.Execute(); err != nil {
+		if code, ok := err.(ExitCode); ok {
+			return int(code)
+		}
+		var msg, data string
+		if e, ok := err.(*tea.SDKError); ok {
+			msg = fmt.Sprintf("ErrCode: %s, %s", tea.StringValue(e.Code), tea.StringValue(e.Message))
+			data = tea.StringValue(e.Data)
+		} else {
+			msg = err.Error()
+		}
+		fmt.Fprintf(os.Stderr, "Failed to execute '%s' command! [%s.]\n", name, msg)
+		if options.Verbose && data != "" {
+			fmt.Fprintln(os.Stderr, data)
+		}
+		return int(exitCodeOf(err))
+	}
+
+	return 0
+}
@@ -4,12 +4,14 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/kdiot/alidns-console/utility"
+	"github.com/alibabacloud-go/tea/tea"
 )
 
 type CmdRm struct {
 	Cmd
-	RecordId string
+	RecordId  string
+	SubDomain string
+	Type      string
 }
 
 func (cmd *CmdRm) init() error {
@@ -19,6 +21,9 @@ func (cmd *CmdRm) init() error {
 	}
 
 	cmd.flagSet.StringVar(&cmd.RecordId, "id", "", "id of domain name record")
+	cmd.flagSet.StringVar(&cmd.SubDomain, "subdomain", "", "remove the records of the subdomain of this RR, '*.staging' removes every subdomain under 'staging'")
+	cmd.flagSet.StringVar(&cmd.Type, "type", "", "only remove the records of this type, with -subdomain")
+	cmd.confirmVar()
 
 	return nil
 }
@@ -29,8 +34,12 @@ func (cmd *CmdRm) Check() error {
 		return err
 	}
 
-	if cmd.RecordId == "" {
-		return errors.New("domain name record id must be specified")
+	if cmd.RecordId == "" && cmd.SubDomain == "" {
+		return errors.New("domain name record id or subdomain must be specified")
+	}
+
+	if cmd.RecordId != "" && cmd.SubDomain != "" {
+		return errors.New("-id and -subdomain cannot be specified together")
 	}
 
 	return nil
@@ -38,19 +47,70 @@ func (cmd *CmdRm) Check() error {
 
 func (cmd *CmdRm) Execute() error {
 
-	api, err := utility.NewAlidnsApi(cmd.DomainName, cmd.AccessKeyId, cmd.AccessKeySecret)
+	if cmd.SubDomain != "" {
+		return cmd.removeSubDomain()
+	}
+
+	api, err := cmd.newApi()
 	if err != nil {
 		return err
 	}
 
+	record, err := api.Retrieve(cmd.RecordId)
+	if err != nil {
+		return err
+	}
+	if err := cmd.confirmChanges("Delete this domain name record?", []*recordChange{{before: record}}); err != nil {
+		return err
+	}
+
 	if err = api.Delete(cmd.RecordId); err != nil {
 		return err
 	} else {
+		cmd.dryRunNotice()
 		fmt.Printf("The domain name record with ID '%s' was successfully deleted!\n", cmd.RecordId)
 		return nil
 	}
 }
 
+// removeSubDomain shows the records of the subdomain and deletes them once
+// the user has confirmed it.
+func (cmd *CmdRm) removeSubDomain() error {
+
+	api, err := cmd.newApi()
+	if err != nil {
+		return err
+	}
+
+	records, err := api.SubDomainRecords(cmd.SubDomain, cmd.Type)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Printf("There is no domain name record of the subdomain '%s'.\n", api.SubDomain(cmd.SubDomain))
+		return nil
+	}
+
+	changes := make([]*recordChange, 0, len(records))
+	for _, record := range records {
+		changes = append(changes, &recordChange{before: record})
+	}
+	if err := cmd.confirmChanges(fmt.Sprintf("Delete these %d domain name records?", len(records)), changes); err != nil {
+		return err
+	}
+
+	// only the records shown are deleted, not those created since the preview
+	for _, record := range records {
+		if err := api.Delete(tea.StringValue(record.RecordId)); err != nil {
+			return err
+		}
+	}
+
+	cmd.dryRunNotice()
+	fmt.Printf("%d domain name records of the subdomain '%s' were successfully deleted!\n", len(records), api.SubDomain(cmd.SubDomain))
+	return nil
+}
+
 func NewCmdRm() *CmdRm {
 	cmd := CmdRm{}
 	if err := cmd.init(); err != nil {
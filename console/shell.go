@@ -0,0 +1,374 @@
+package console
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+)
+
+// maxHistory is the number of lines kept in the history file.
+const maxHistory = 1000
+
+// shellCommand is a command that can run in a shell session.
+type shellCommand interface {
+	Command
+	attach(s *session)
+	flags() *flag.FlagSet
+}
+
+// readOnlyCommands do not change the records, the completion cache is kept.
+var readOnlyCommands = map[string]bool{
+	"record ls":     true,
+	"record export": true,
+	"domain ls":     true,
+	"slb ls":        true,
+	"lines ls":      true,
+	"backup":        true,
+	"ls":            true,
+}
+
+// shellLookup finds the command of the arguments among those the shell runs,
+// walking the groups. It returns the entry of the command, the group it was
+// found in and its arguments. The entry is nil if the arguments name a group
+// without a subcommand, then no argument is left, or an unknown command.
+func (r *Registry) shellLookup(args []string) (*entry, *Registry, []string) {
+	for len(args) > 0 {
+		e := r.lookup(args[0])
+		switch {
+		case e == nil:
+			return nil, r, args
+		case e.group != nil:
+			r, args = e.group, args[1:]
+		case e.create != nil:
+			return e, r, args[1:]
+		default:
+			// the command is not run by the shell
+			return nil, r, args
+		}
+	}
+	return nil, r, nil
+}
+
+// shellNames returns the names of the visible commands the shell runs, and of
+// the groups having some.
+func (r *Registry) shellNames() []string {
+	var names []string
+	for _, e := range r.entries {
+		if e.summary == "" {
+			continue
+		}
+		if e.create != nil || (e.group != nil && len(e.group.shellNames()) > 0) {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+var shellBuiltins = []string{"use", "help", "exit", "quit"}
+
+// session is the state shared by the commands of a shell: the profile, the
+// client and the records cached for completion.
+type session struct {
+	registry *Registry
+	profile  Profile
+	api      *utility.AlidnsApi
+	records  []*utility.DomainRecord
+	cached   bool
+}
+
+func (s *session) client() (*utility.AlidnsApi, error) {
+	if s.api == nil {
+		api, err := utility.NewAlidnsApi(s.profile.DomainName, s.profile.AccessKeyId, s.profile.AccessKeySecret)
+		if err != nil {
+			return nil, err
+		}
+		s.api = api
+	}
+	return s.api, nil
+}
+
+// cachedRecords returns the records of the current domain, queried once until
+// a command changes them.
+func (s *session) cachedRecords() []*utility.DomainRecord {
+	if !s.cached && s.profile.DomainName != "" {
+		s.cached = true
+		if api, err := s.client(); err == nil {
+			api.DomainName = s.profile.DomainName
+			api.DryRun = false
+			s.records, _ = api.Query(&utility.QueryInfo{})
+		}
+	}
+	return s.records
+}
+
+func (s *session) invalidate() {
+	s.records, s.cached = nil, false
+}
+
+// complete returns the candidates for the word ending at pos: the commands,
+// their flags, and the RRs or record IDs after -rr, -subdomain and -id.
+func (s *session) complete(line string, pos int) (int, []string) {
+	line = line[:pos]
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+	fields := strings.Fields(line[:start])
+
+	var words []string
+	if len(fields) == 0 {
+		words = append(s.registry.shellNames(), shellBuiltins...)
+	} else if fields[0] == "help" {
+		if e, r, rest := s.registry.shellLookup(fields[1:]); e == nil && len(rest) == 0 {
+			words = r.shellNames()
+		}
+	} else if e, r, rest := s.registry.shellLookup(fields); e == nil {
+		if len(rest) == 0 && !strings.HasPrefix(word, "-") {
+			// the subcommands of a group
+			words = r.shellNames()
+		}
+	} else if strings.HasPrefix(word, "-") {
+		if f, ok := e.command.(interface{ flags() *flag.FlagSet }); ok {
+			words = flagNames(f.flags(), word)
+		}
+	} else {
+		seen := map[string]bool{}
+		switch strings.TrimLeft(fields[len(fields)-1], "-") {
+		case "rr", "subdomain":
+			for _, record := range s.cachedRecords() {
+				if rr := tea.StringValue(record.RR); !seen[rr] {
+					seen[rr] = true
+					words = append(words, rr)
+				}
+			}
+		case "id":
+			for _, record := range s.cachedRecords() {
+				words = append(words, tea.StringValue(record.RecordId))
+			}
+		}
+	}
+
+	var candidates []string
+	for _, w := range words {
+		if strings.HasPrefix(w, word) {
+			candidates = append(candidates, w)
+		}
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
+
+// CmdShell runs the commands of the registry that the shell can run, see
+// Registry.AddFunc, in a session keeping the client.
+type CmdShell struct {
+	Cmd
+	HistoryFile string
+	registry    *Registry
+}
+
+func (cmd *CmdShell) init() error {
+
+	if err := cmd.Cmd.init("shell"); err != nil {
+		return err
+	}
+
+	history := ".alidns_history"
+	if user, err := user.Current(); err == nil {
+		history = filepath.Join(user.HomeDir, history)
+	}
+	cmd.flagSet.StringVar(&cmd.HistoryFile, "history", history, "file of the command history, empty disables it")
+
+	return nil
+}
+
+func (cmd *CmdShell) Check() error {
+
+	// the domain can be chosen in the shell with 'use'
+	if cmd.AccessKeyId == "" {
+		return authError{errors.New("access key id is not specified")}
+	}
+
+	if cmd.AccessKeySecret == "" {
+		return authError{errors.New("access key secret is not specified")}
+	}
+
+	return nil
+}
+
+func (cmd *CmdShell) Execute() error {
+
+	s := &session{registry: cmd.registry, profile: cmd.Profile}
+	editor := newLineEditor(s.complete)
+
+	var history *os.File
+	if cmd.HistoryFile != "" {
+		if data, err := os.ReadFile(cmd.HistoryFile); err == nil {
+			lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+			if len(lines) > maxHistory {
+				lines = lines[len(lines)-maxHistory:]
+				os.WriteFile(cmd.HistoryFile, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+			}
+			for _, line := range lines {
+				editor.addHistory(line)
+			}
+		}
+		if file, err := os.OpenFile(cmd.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+			history = file
+			defer file.Close()
+		}
+	}
+
+	if editor.terminal {
+		fmt.Println("Type 'help' for the commands, Tab completes them, 'exit' or Ctrl-D quits.")
+	}
+	for {
+		prompt := "alidns> "
+		if s.profile.DomainName != "" {
+			prompt = fmt.Sprintf("alidns:%s> ", s.profile.DomainName)
+		}
+
+		line, err := editor.readLine(prompt)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args, err := splitArgs(line)
+		// the lines giving credentials are only kept in the session
+		if editor.addHistory(line) && history != nil && err == nil && !hasCredentials(args) {
+			fmt.Fprintln(history, line)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error! %s.\n", err.Error())
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		switch args[0] {
+		case "exit", "quit":
+			return nil
+		case "help":
+			cmd.help(args[1:])
+		case "use":
+			if len(args) != 2 {
+				fmt.Fprintln(os.Stderr, "Usage:  use DOMAIN")
+				continue
+			}
+			s.profile.DomainName = args[1]
+			s.invalidate()
+		default:
+			cmd.run(s, args)
+		}
+	}
+}
+
+// hasCredentials tells whether the arguments give the access key, with -key
+// or -secret.
+func hasCredentials(args []string) bool {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "key" || name == "secret" {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmd *CmdShell) help(args []string) {
+	if len(args) > 0 {
+		e, r, rest := cmd.registry.shellLookup(args)
+		switch {
+		case e != nil && len(rest) == 0:
+			e.create().Usage()
+		case e == nil && len(rest) == 0:
+			r.usage(os.Stdout)
+		default:
+			fmt.Fprintf(os.Stderr, "Error! Could not find help for command '%s'.\n", strings.Join(args, " "))
+		}
+		return
+	}
+
+	fmt.Println("Commands:")
+	for _, name := range cmd.registry.shellNames() {
+		fmt.Printf("  %-11s %s\n", name, cmd.registry.lookup(name).summary)
+	}
+	fmt.Println("  use         Switch to the records of another domain: use DOMAIN")
+	fmt.Println("  help        Print help information for specific commands, such as: help record ls")
+	fmt.Println("  exit        Quit the shell")
+}
+
+// run runs a command of the shell, reporting its errors on stderr the way the
+// program does.
+func (cmd *CmdShell) run(s *session, args []string) {
+	e, r, arguments := cmd.registry.shellLookup(args)
+	if e == nil {
+		switch {
+		case len(arguments) == 0:
+			fmt.Fprintf(os.Stderr, "Error! The command '%s' needs a subcommand: %s.\n", r.path, strings.Join(r.shellNames(), ", "))
+		case r.path != "" && isHelpFlag(arguments[0]):
+			r.usage(os.Stdout)
+		default:
+			fmt.Fprintf(os.Stderr, "Error! Unknown command '%s', type 'help' for the commands.\n", strings.TrimSpace(r.path+" "+arguments[0]))
+		}
+		return
+	}
+	name := strings.TrimSpace(r.path + " " + e.name)
+
+	c, ok := e.create().(shellCommand)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error! The command '%s' cannot run in the shell.\n", name)
+		return
+	}
+	c.attach(s)
+	if err := c.Parse(arguments); err != nil {
+		// the flag package has reported the errors of the flags
+		if _, ok := err.(flagError); !ok && err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "Illegal parameter of command '%s': %s\n", name, err.Error())
+		}
+		return
+	}
+	if err := c.Check(); err != nil {
+		fmt.Fprintf(os.Stderr, "Command line '%s' parameter check failed: %s\n", name, err.Error())
+		return
+	}
+	if !readOnlyCommands[name] {
+		defer s.invalidate()
+	}
+	if err := c.Execute(); err != nil {
+		if _, ok := err.(ExitCode); ok {
+			return
+		}
+		var msg string
+		if e, ok := err.(*tea.SDKError); ok {
+			msg = fmt.Sprintf("ErrCode: %s, %s", tea.StringValue(e.Code), tea.StringValue(e.Message))
+		} else {
+			msg = err.Error()
+		}
+		fmt.Fprintf(os.Stderr, "Failed to execute '%s' command! [%s.]\n", name, msg)
+	}
+}
+
+func NewCmdShell(registry *Registry) *CmdShell {
+	cmd := CmdShell{registry: registry}
+	if err := cmd.init(); err != nil {
+		panic(err)
+	} else {
+		return &cmd
+	}
+}
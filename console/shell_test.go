@@ -0,0 +1,86 @@
+package console
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHasCredentials(t *testing.T) {
+	for line, want := range map[string]bool{
+		"ls -rr www":                      false,
+		"rm -id 123456":                   false,
+		"ls -key LTAI5t -secret s3cr3t":   true,
+		"ls --secret=s3cr3t":              true,
+		"add -rr www -value 'my -secret'": false,
+		"ls -profile prod":                false,
+	} {
+		args, err := splitArgs(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hasCredentials(args); got != want {
+			t.Errorf("'%s': got %v, want %v", line, got, want)
+		}
+	}
+}
+
+func testRegistry() *Registry {
+	registry := NewRegistry("test")
+	record := registry.Group("record", "Manage the domain name records")
+	record.AddFunc(func() Command { return NewCmdLs() }, "List domain name records")
+	record.AddFunc(func() Command { return NewCmdRm() }, "Remove a domain name record")
+	domain := registry.Group("domain", "Manage the domains")
+	domain.AddFunc(func() Command { return NewCmdDomain() }, "List the domains")
+	ddns := registry.Group("ddns", "Update the records")
+	ddns.Add(NewCmdDdns("run"), "Run the daemon")
+	registry.Add(NewCmdShell(registry), "Run the shell")
+	registry.AddFunc(func() Command { return NewCmdLs() }, "")
+	return registry
+}
+
+func TestShellLookup(t *testing.T) {
+	registry := testRegistry()
+
+	for line, want := range map[string]string{
+		"record ls -rr www": "record ls",
+		"domain ls":         "domain ls",
+		"ls -rr www":        "ls",
+		"record":            "",
+		"ddns run":          "",
+		"shell":             "",
+		"record foo":        "",
+	} {
+		args, _ := splitArgs(line)
+		e, r, _ := registry.shellLookup(args)
+		got := ""
+		if e != nil {
+			got = strings.TrimSpace(r.path + " " + e.name)
+		}
+		if got != want {
+			t.Errorf("'%s': got command '%s', want '%s'", line, got, want)
+		}
+	}
+
+	// the commands the shell does not run are not listed
+	if names := strings.Join(registry.shellNames(), " "); names != "record domain" {
+		t.Errorf("got the commands '%s'", names)
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	s := &session{registry: testRegistry()}
+
+	for line, want := range map[string]string{
+		"":             "domain exit help quit record use",
+		"rec":          "record",
+		"record ":      "ls rm",
+		"help domain ": "ls",
+		"domain ls -k": "-key -keyword",
+		"ddns ":        "",
+	} {
+		_, candidates := s.complete(line, len(line))
+		if got := strings.Join(candidates, " "); got != want {
+			t.Errorf("'%s': got candidates '%s', want '%s'", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+	"github.com/olekukonko/tablewriter"
+)
+
+// slbActions are the subcommands of 'slb', with the line of their usage.
+var slbActions = map[string]string{
+	"ls":      "List the subdomains whose records can be weighted, and the weights of the records of -rr",
+	"enable":  "Enable weighted round-robin of the records of -rr",
+	"disable": "Disable weighted round-robin of the records of -rr",
+	"weight":  "Set the weight of the record -id to -weight",
+}
+
+// CmdSlb manages the weighted round-robin (SLB) of the subdomains having
+// several records of a type, it is one of 'slb ls|enable|disable|weight'.
+type CmdSlb struct {
+	Cmd
+	action   string
+	RR       string
+	Type     string
+	Line     string
+	RecordId string
+	Weight   int
+}
+
+func (cmd *CmdSlb) init(action string) error {
+
+	summary, ok := slbActions[action]
+	if !ok {
+		return fmt.Errorf("unknown slb action '%s'", action)
+	}
+	if err := cmd.Cmd.init(action); err != nil {
+		return err
+	}
+	cmd.action = action
+
+	if action == "weight" {
+		cmd.flagSet.StringVar(&cmd.RecordId, "id", "", "id of domain name record")
+		cmd.flagSet.IntVar(&cmd.Weight, "weight", 0, "weight of the domain name record from 1 to 100")
+	} else {
+		cmd.flagSet.StringVar(&cmd.RR, "rr", "", "resource record of the subdomain, '@' for the domain itself")
+		cmd.flagSet.StringVar(&cmd.Type, "type", "", "domain name record type of the weighted records, A|AAAA|CNAME")
+		cmd.flagSet.StringVar(&cmd.Line, "line", "", "resolution line of the weighted records")
+	}
+
+	usage := cmd.flagSet.Usage
+	cmd.flagSet.Usage = func() {
+		fmt.Printf("Usage:  alidns slb %s [OPTIONS]\n", action)
+		fmt.Println(summary)
+		usage()
+	}
+
+	return nil
+}
+
+func (cmd *CmdSlb) Check() error {
+
+	if err := cmd.Cmd.Check(); err != nil {
+		return err
+	}
+
+	switch cmd.action {
+	case "enable", "disable":
+		if cmd.RR == "" {
+			return errors.New("RR must be specified")
+		}
+	case "weight":
+		if cmd.RecordId == "" {
+			return errors.New("domain name record id must be specified")
+		}
+		if cmd.Weight < 1 || cmd.Weight > 100 {
+			return errors.New("the weight must be between 1 and 100")
+		}
+	}
+
+	return nil
+}
+
+func (cmd *CmdSlb) Execute() error {
+
+	api, err := cmd.newApi()
+	if err != nil {
+		return err
+	}
+
+	switch cmd.action {
+	case "enable", "disable":
+		if err := api.SetSlbStatus(api.SubDomain(cmd.RR), cmd.Type, cmd.Line, cmd.action == "enable"); err != nil {
+			return err
+		}
+		cmd.dryRunNotice()
+		fmt.Printf("Weighted round-robin of '%s' was successfully %sd!\n", api.SubDomain(cmd.RR), cmd.action)
+		return nil
+	case "weight":
+		if err := api.SetWeight(cmd.RecordId, int32(cmd.Weight)); err != nil {
+			return err
+		}
+		cmd.dryRunNotice()
+		fmt.Printf("The weight of the domain name record with ID '%s' was successfully set to %d!\n", cmd.RecordId, cmd.Weight)
+		return nil
+	}
+
+	subDomains, err := api.SlbSubDomains(cmd.RR)
+	if err != nil {
+		return err
+	}
+
+	var records []*utility.DomainRecord
+	if cmd.RR != "" {
+		query := &utility.QueryInfo{RR: &cmd.RR}
+		if cmd.Type != "" {
+			query.Type = &cmd.Type
+		}
+		if cmd.Line != "" {
+			query.Line = &cmd.Line
+		}
+		if records, err = api.Query(query); err != nil {
+			return err
+		}
+		// the query matches the RR as a keyword
+		matched := records[:0]
+		for _, record := range records {
+			if *record.RR == cmd.RR {
+				matched = append(matched, record)
+			}
+		}
+		records = matched
+	}
+
+	if jsonOutput() {
+		return printJSON(struct {
+			SubDomains []*utility.SlbSubDomain `json:"SubDomains"`
+			Records    []*utility.DomainRecord `json:"Records,omitempty"`
+		}{subDomains, records})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"SUBDOMAIN", "TYPE", "RECORDS", "STATUS", "LINES"})
+	for _, subDomain := range subDomains {
+		var lines []string
+		if subDomain.LineAlgorithms != nil {
+			for _, algorithm := range subDomain.LineAlgorithms.LineAlgorithm {
+				if tea.BoolValue(algorithm.Open) {
+					lines = append(lines, tea.StringValue(algorithm.Line))
+				}
+			}
+		}
+		table.Append([]string{
+			tea.StringValue(subDomain.SubDomain),
+			tea.StringValue(subDomain.Type),
+			fmt.Sprintf("%d", tea.Int64Value(subDomain.RecordCount)),
+			openStatus(subDomain.Open),
+			strings.Join(lines, ","),
+		})
+	}
+	table.Render()
+
+	if cmd.RR == "" {
+		return nil
+	}
+
+	table = tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "RR", "TYPE", "VALUE", "LINE", "WEIGHT", "STATUS"})
+	for _, record := range records {
+		table.Append([]string{
+			*record.RecordId,
+			*record.RR,
+			*record.Type,
+			*record.Value,
+			tea.StringValue(record.Line),
+			weight(record.Weight),
+			*record.Status,
+		})
+	}
+	table.Render()
+
+	return nil
+}
+
+// weight formats the weight of a record, which is only set for weighted records.
+func weight(w *int32) string {
+	if w == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d", *w)
+}
+
+func openStatus(open *bool) string {
+	if tea.BoolValue(open) {
+		return "ENABLE"
+	}
+	return "DISABLE"
+}
+
+// NewCmdSlb creates the subcommand of 'slb' of the action: ls, enable,
+// disable or weight.
+func NewCmdSlb(action string) *CmdSlb {
+	cmd := CmdSlb{}
+	if err := cmd.init(action); err != nil {
+		panic(err)
+	} else {
+		return &cmd
+	}
+}
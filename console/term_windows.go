@@ -0,0 +1,19 @@
+//go:build windows
+
+package console
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isTerminal reports whether the handle is a console.
+func isTerminal(fd int) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(fd), &mode) == nil
+}
+
+// makeRaw is not supported on Windows, the lines are read as they are typed.
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("the raw mode of the console is not supported")
+}
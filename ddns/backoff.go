@@ -0,0 +1,58 @@
+package ddns
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/kdiot/alidns-console/utility"
+)
+
+// throttleInterval is the shortest delay before retrying a throttled request.
+const throttleInterval = 60 * time.Second
+
+// Backoff computes the delays between retries, doubling the delay after every
+// failure up to Max, with a random jitter so that many daemons sharing an
+// account do not retry in lockstep. Base and Max are in seconds.
+type Backoff struct {
+	Base     time.Duration
+	Max      time.Duration
+	attempts int
+}
+
+func (b *Backoff) Reset() {
+	b.attempts = 0
+}
+
+// Next returns the delay before the next retry of a request that failed with
+// an error of the given class.
+func (b *Backoff) Next(class utility.ErrorClass) time.Duration {
+	b.attempts++
+	if class == utility.ERROR_THROTTLED {
+		// back off faster when the API asks us to slow down
+		b.attempts++
+	}
+
+	base := b.Base * time.Second
+	max := b.Max * time.Second
+	if max < base {
+		max = base
+	}
+
+	delay := base
+	for i := 1; i < b.attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	// equal jitter: wait at least half of the delay
+	if half := int64(delay / 2); half > 0 {
+		delay = time.Duration(half + rand.Int63n(half+1))
+	}
+
+	if class == utility.ERROR_THROTTLED && delay < throttleInterval {
+		delay = throttleInterval
+	}
+	return delay
+}
@@ -5,9 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"reflect"
+	"strings"
 	"time"
 
+	"github.com/alibabacloud-go/tea/tea"
 	"github.com/kdiot/alidns-console/utility"
 )
 
@@ -19,6 +23,20 @@ type DDNS struct {
 	Type            *string `json:"Type"`
 	TTL             *int64  `json:"TTL"`
 	Network         *string `json:"Network"`
+	// Line is the resolution line of the record, such as 'telecom', the
+	// default line if it is not specified.
+	Line *string `json:"Line"`
+	// Sources makes a multi-value record, holding the IP addresses detected by
+	// all the sources, such as the interfaces of the uplinks: "if:wan1".
+	Sources []string `json:"Sources"`
+	// Remark tags the records owned by a multi-value record, the other records
+	// of the RR and Type are never modified.
+	Remark *string `json:"Remark"`
+	// Failover keeps the record pointing at the first healthy of the candidate
+	// values, instead of the detected IP address.
+	Failover *FailoverConfig `json:"Failover"`
+	// delegated entries are derived from the delegated prefix configuration
+	delegated bool
 }
 
 func (d *DDNS) Check() error {
@@ -37,18 +55,104 @@ func (d *DDNS) Check() error {
 	if d.Type == nil || (*d.Type != "A" && *d.Type != "AAAA") {
 		return errors.New("domain name record type must be 'A' or 'AAAA'")
 	}
+	if d.Failover != nil {
+		if len(d.Sources) > 0 {
+			return errors.New("a failover record cannot have Sources")
+		}
+		if len(d.Failover.Candidates) == 0 {
+			return errors.New("the Candidates of a failover record cannot be empty")
+		}
+		for _, value := range d.Failover.Candidates {
+			ip := net.ParseIP(value)
+			if ip == nil || (ip.To4() != nil) != (*d.Type == "A") {
+				return fmt.Errorf("the failover candidate '%s' is not an IP address of a type '%s' record", value, *d.Type)
+			}
+		}
+	}
 	return nil
 }
 
+// Key identifies the domain name record managed by the DDNS entry, the line
+// is appended unless it is the default one.
+func (d *DDNS) Key() string {
+	key := fmt.Sprintf("%s.%s/%s", tea.StringValue(d.RR), tea.StringValue(d.DomainName), tea.StringValue(d.Type))
+	if line := utility.LineValue(d.Line); line != utility.DefaultLine {
+		key += "/" + line
+	}
+	return key
+}
+
+// Equal reports whether both DDNS entries have the same settings.
+func (d *DDNS) Equal(other *DDNS) bool {
+	if d == nil || other == nil {
+		return d == other
+	}
+	return tea.StringValue(d.AccessKeyId) == tea.StringValue(other.AccessKeyId) &&
+		tea.StringValue(d.AccessKeySecret) == tea.StringValue(other.AccessKeySecret) &&
+		tea.StringValue(d.DomainName) == tea.StringValue(other.DomainName) &&
+		tea.StringValue(d.RR) == tea.StringValue(other.RR) &&
+		tea.StringValue(d.Type) == tea.StringValue(other.Type) &&
+		tea.Int64Value(d.TTL) == tea.Int64Value(other.TTL) &&
+		tea.StringValue(d.Network) == tea.StringValue(other.Network) &&
+		utility.LineValue(d.Line) == utility.LineValue(other.Line) &&
+		strings.Join(d.Sources, ",") == strings.Join(other.Sources, ",") &&
+		tea.StringValue(d.Remark) == tea.StringValue(other.Remark) &&
+		reflect.DeepEqual(d.Failover, other.Failover)
+}
+
+// DynDNSUser is an account of the dyndns2 update server, allowed to update the
+// listed host names, "*" allows all of them.
+type DynDNSUser struct {
+	Username  string   `json:"Username"`
+	Password  string   `json:"Password"`
+	Hostnames []string `json:"Hostnames"`
+}
+
+// DynDNSConfig configures the dyndns2 compatible update server started by
+// 'ddns serve'. The server uses HTTPS if CertFile and KeyFile are specified.
+type DynDNSConfig struct {
+	Listen   string        `json:"Listen"`
+	CertFile string        `json:"CertFile"`
+	KeyFile  string        `json:"KeyFile"`
+	Users    []*DynDNSUser `json:"Users"`
+}
+
 type Config struct {
-	AccessKeyId     *string          `json:"AccessKeyId"`
-	AccessKeySecret *string          `json:"AccessKeySecret"`
-	DomainName      *string          `json:"DomainName"`
-	LogFile         *string          `json:"LogFile"`
-	LogLevel        utility.LogLevel `json:"LogLevel"`
-	CheckInterval   time.Duration    `json:"CheckInterval"`
-	RetryInterval   time.Duration    `json:"RetryInterval"`
-	DomainList      []*DDNS          `json:"DomainList"`
+	AccessKeyId       *string           `json:"AccessKeyId"`
+	AccessKeySecret   *string           `json:"AccessKeySecret"`
+	DomainName        *string           `json:"DomainName"`
+	LogFile           *string           `json:"LogFile"`
+	LogLevel          utility.LogLevel  `json:"LogLevel"`
+	StateFile         *string           `json:"StateFile"`
+	Listen            *string           `json:"Listen"`
+	MetricsListen     *string           `json:"MetricsListen"`
+	CheckInterval     time.Duration     `json:"CheckInterval"`
+	RetryInterval     time.Duration     `json:"RetryInterval"`
+	MaxRetryInterval  time.Duration     `json:"MaxRetryInterval"`
+	ReconcileInterval time.Duration     `json:"ReconcileInterval"`
+	DryRun            bool              `json:"DryRun"`
+	FailureThreshold  int               `json:"FailureThreshold"`
+	Notifiers         []*NotifierConfig `json:"Notifiers"`
+	DynDNS            *DynDNSConfig     `json:"DynDNS"`
+	Prefix            *PrefixConfig     `json:"Prefix"`
+	Discovery         *DiscoveryConfig  `json:"Discovery"`
+	DomainList        []*DDNS           `json:"DomainList"`
+}
+
+// setDefaults replaces the settings that are unset or out of range with their defaults.
+func (conf *Config) setDefaults() {
+	if conf.CheckInterval <= 0 {
+		conf.CheckInterval = 10
+	}
+	if conf.MaxRetryInterval <= 0 {
+		conf.MaxRetryInterval = 3600
+	}
+	if conf.FailureThreshold <= 0 {
+		conf.FailureThreshold = 3
+	}
+	if conf.Discovery != nil && conf.Discovery.StaleAfter <= 0 {
+		conf.Discovery.StaleAfter = 3600
+	}
 }
 
 func (conf *Config) Load(fileName string) error {
@@ -66,9 +170,16 @@ func (conf *Config) Load(fileName string) error {
 	err = json.Unmarshal([]byte(data), &conf)
 	if err != nil {
 		return err
-	} else {
-		return nil
 	}
+
+	if conf.Prefix != nil {
+		hosts, err := conf.Prefix.expand()
+		if err != nil {
+			return err
+		}
+		conf.DomainList = append(conf.DomainList, hosts...)
+	}
+	return nil
 }
 
 func LoadConfig(fileName string) (*Config, error) {
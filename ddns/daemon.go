@@ -2,99 +2,774 @@ package ddns
 
 import (
 	"context"
+	"net"
 	"os"
 	"os/signal"
+	"reflect"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/alibabacloud-go/tea/tea"
 	"github.com/kdiot/alidns-console/utility"
 )
 
+// ConfigLoader reads the configuration again when the daemon is asked to reload it.
+type ConfigLoader func() (*Config, error)
+
 type Daemon struct {
-	config   *Config
-	services []*UpdateService
-	ipv4     ExternalIP
-	ipv6     ExternalIP
+	config     *Config
+	loader     ConfigLoader
+	services   []*UpdateService
+	ipv4       ExternalIP
+	ipv6       ExternalIP
+	prefix     ExternalIP
+	state      *State
+	notifiers  *Notifiers
+	retired    []*Notifiers
+	ctx        context.Context
+	wg         sync.WaitGroup
+	control    chan func()
+	sdStatus   string
+	dyndns     net.Listener
+	submitted  map[string]net.IP
+	discovered map[string]*discoveredHost
+}
+
+// discoveredHost is the publishing state of a host found on the LAN.
+type discoveredHost struct {
+	service *UpdateService
+	ip      net.IP
+	seen    time.Time
 }
 
 func (daemon *Daemon) init(conf *Config) error {
+	conf.setDefaults()
 	daemon.config = conf
 	daemon.ipv4 = NewExternalIPv4(nil)
 	daemon.ipv6 = NewExternalIPv6(nil)
+	daemon.setPrefix(conf.Prefix)
+
+	if conf.StateFile != nil && *conf.StateFile != "" {
+		state, err := LoadState(*conf.StateFile)
+		if err != nil {
+			utility.Warningf("Failed to load the state file '%s', all records will be updated: %s", *conf.StateFile, err.Error())
+			state = &State{fileName: *conf.StateFile}
+		}
+		state.readOnly = conf.DryRun
+		daemon.state = state
+	}
+
+	notifiers, err := NewNotifiers(conf.Notifiers)
+	if err != nil {
+		return err
+	}
+	if notifiers != nil {
+		notifiers.DryRun = conf.DryRun
+	}
+	daemon.notifiers = notifiers
+
+	if conf.DryRun {
+		utility.Warning("Dry run! The domain name records will not be changed.")
+	}
 
+	for _, d := range conf.DomainList {
+		if service := daemon.newService(d, conf); service != nil {
+			daemon.services = append(daemon.services, service)
+		}
+	}
+
+	return nil
+}
+
+func (daemon *Daemon) newService(d *DDNS, conf *Config) *UpdateService {
+	if err := d.Check(); err != nil {
+		utility.Errorf("Dynamic domain name configuration error: %s", err.Error())
+		return nil
+	}
+	service, err := NewUpdateService(d, conf)
+	if err != nil {
+		utility.Errorf("An error occurred while creating the AliDDNS object, the reason for the error: %s", utility.ErrMsg(err))
+		return nil
+	}
+	service.SetState(daemon.state)
+	service.SetNotifiers(daemon.notifiers)
+	service.delegated = d.delegated
+	daemon.setSources(service, d)
+	if err := daemon.setFailover(service, d); err != nil {
+		utility.Errorf("Dynamic domain name configuration error: %s", err.Error())
+		return nil
+	}
+	return service
+}
+
+// setFailover creates the monitor of the candidates of a failover record, or
+// replaces it when its configuration changed.
+func (daemon *Daemon) setFailover(service *UpdateService, d *DDNS) error {
+	if service.failover != nil && reflect.DeepEqual(service.failover.conf, d.Failover) {
+		return nil
+	}
+	var m *monitor
+	if d.Failover != nil {
+		var err error
+		if m, err = newMonitor(service, d.Failover); err != nil {
+			return err
+		}
+	}
+	if service.failover != nil && service.failover.cancel != nil {
+		service.failover.cancel()
+	}
+	service.failover = m
+	if m != nil && service.cancel != nil {
+		daemon.startMonitor(service)
+	}
+	return nil
+}
+
+func (daemon *Daemon) startMonitor(service *UpdateService) {
+	ctx, cancel := context.WithCancel(service.ctx)
+	service.failover.cancel = cancel
+	daemon.wg.Add(1)
+	go service.failover.Routine(ctx, &daemon.wg)
+}
+
+// setPrefix creates the detector of the delegated prefix when it is read from
+// a network interface, the prefix is otherwise taken from the IPv6 address.
+func (daemon *Daemon) setPrefix(conf *PrefixConfig) {
+	if conf == nil || tea.StringValue(conf.Interface) == "" {
+		daemon.prefix = nil
+		return
+	}
+	provider := interfacePrefix + *conf.Interface
+	if daemon.prefix == nil || daemon.config.Prefix == nil ||
+		tea.StringValue(daemon.config.Prefix.Interface) != *conf.Interface {
+		daemon.prefix = NewExternalIPv6([]string{provider})
+	}
+}
+
+// detector returns the detector of the IP address that the record of the
+// service follows.
+func (daemon *Daemon) detector(service *UpdateService) ExternalIP {
+	switch {
+	case service.delegated && daemon.prefix != nil:
+		return daemon.prefix
+	case service.recordType == "AAAA":
+		return daemon.ipv6
+	default:
+		return daemon.ipv4
+	}
+}
+
+func (daemon *Daemon) start(service *UpdateService) {
+	ctx, cancel := context.WithCancel(daemon.ctx)
+	service.ctx, service.cancel = ctx, cancel
+	daemon.wg.Add(1)
+	go service.Routine(ctx, &daemon.wg)
+	if service.failover != nil {
+		daemon.startMonitor(service)
+	}
+}
+
+// SetConfigLoader enables reloading the configuration on SIGHUP.
+func (daemon *Daemon) SetConfigLoader(loader ConfigLoader) {
+	daemon.loader = loader
+}
+
+func (daemon *Daemon) current(recordType string) net.IP {
+	switch recordType {
+	case "A":
+		return daemon.ipv4.Current()
+	case "AAAA":
+		return daemon.ipv6.Current()
+	default:
+		return nil
+	}
+}
+
+// address returns the IP address the record of the service should hold, that
+// is the healthy candidate of a failover record, the detected one, or the one
+// submitted by a dyndns2 client when serving.
+func (daemon *Daemon) address(service *UpdateService) net.IP {
+	if service.failover != nil {
+		return service.failover.Current()
+	}
+	if daemon.dyndns != nil {
+		return daemon.submitted[service.key]
+	}
+	if service.discovered {
+		if host, ok := daemon.discovered[service.key]; ok {
+			return host.ip
+		}
+		return nil
+	}
+	return daemon.detector(service).Current()
+}
+
+// addresses returns the IP addresses the record of the service should hold,
+// the addresses of all its sources for a multi-value record. The addresses
+// not detected yet are left out.
+func (daemon *Daemon) addresses(service *UpdateService) []net.IP {
+	var ips []net.IP
+	if len(service.sources) > 0 && daemon.dyndns == nil {
+		for _, source := range service.sources {
+			if ip := source.Current(); ip != nil && !ip.IsUnspecified() {
+				ips = append(ips, ip)
+			}
+		}
+	} else if ip := daemon.address(service); ip != nil && !ip.IsUnspecified() {
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+// setSources creates the detectors of the sources of a multi-value record.
+func (daemon *Daemon) setSources(service *UpdateService, d *DDNS) {
+	if strings.Join(service.sourceNames, ",") == strings.Join(d.Sources, ",") {
+		return
+	}
+	service.sources = nil
+	for _, source := range d.Sources {
+		if tea.StringValue(d.Type) == "A" {
+			service.sources = append(service.sources, NewExternalIPv4([]string{source}))
+		} else {
+			service.sources = append(service.sources, NewExternalIPv6([]string{source}))
+		}
+	}
+	service.sourceNames = d.Sources
+}
+
+// checkSources refreshes the sources of the multi-value records, and posts
+// the new addresses of the records whose sources changed.
+func (daemon *Daemon) checkSources() {
+	for _, service := range daemon.services {
+		changed := false
+		for _, source := range service.sources {
+			if _, c := source.Refresh(daemon.ctx); c {
+				changed = true
+			}
+		}
+		if changed {
+			ips := daemon.addresses(service)
+			utility.Infof("Detected that the IP addresses(%s) of '%s' have changed, preparing to update the domain name record...", joinIPs(ips), service.key)
+			service.PostUpdate(ips...)
+		}
+	}
+}
+
+func joinIPs(ips []net.IP) string {
+	list := make([]string, len(ips))
+	for i, ip := range ips {
+		list[i] = ip.String()
+	}
+	return strings.Join(list, ",")
+}
+
+// doReload re-reads the configuration, starts services for the added entries,
+// stops the services of the removed ones and reconfigures the others in place.
+func (daemon *Daemon) doReload() {
+	if daemon.loader == nil {
+		utility.Warning("The daemon was not started with a configuration file, nothing to reload.")
+		return
+	}
+
+	conf, err := daemon.loader()
+	if err != nil {
+		utility.Errorf("Failed to reload the configuration, keep the current one: %s", err.Error())
+		return
+	}
+	conf.setDefaults()
+
+	notifiers, err := NewNotifiers(conf.Notifiers)
+	if err != nil {
+		utility.Errorf("Failed to reload the configuration, keep the current one: %s", err.Error())
+		return
+	}
+	if notifiers != nil {
+		notifiers.DryRun = daemon.config.DryRun
+	}
+	// the services keep sending through the previous notifiers until they
+	// get the new ones, they are waited for at shutdown once the services
+	// are stopped
+	if daemon.notifiers != nil {
+		daemon.retired = append(daemon.retired, daemon.notifiers)
+	}
+	daemon.notifiers = notifiers
+
+	daemon.setPrefix(conf.Prefix)
+
+	running := map[string]*UpdateService{}
+	for _, service := range daemon.services {
+		if !service.discovered {
+			running[service.key] = service
+		}
+	}
+
+	// the hosts are discovered again with the new configuration on the next check
+	for key, host := range daemon.discovered {
+		utility.Infof("Stop updating the dynamic domain name record '%s'.", key)
+		host.service.cancel()
+	}
+	daemon.discovered = map[string]*discoveredHost{}
+
+	var services, redetect []*UpdateService
 	for _, d := range conf.DomainList {
 		if err := d.Check(); err != nil {
 			utility.Errorf("Dynamic domain name configuration error: %s", err.Error())
 			continue
 		}
-		service, err := NewUpdateService(d, conf)
-		if err != nil {
-			utility.Errorf("An error occurred while creating the AliDDNS object, the reason for the error: %s", utility.ErrMsg(err))
+		if service, ok := running[d.Key()]; ok {
+			delete(running, d.Key())
+			service.delegated = d.delegated
+			changed := strings.Join(service.sourceNames, ",") != strings.Join(d.Sources, ",") ||
+				(service.failover == nil) != (d.Failover == nil) ||
+				service.failover != nil && !reflect.DeepEqual(service.failover.conf, d.Failover)
+			daemon.setSources(service, d)
+			if err := daemon.setFailover(service, d); err != nil {
+				utility.Errorf("Dynamic domain name configuration error: %s", err.Error())
+			}
+			service.PostConfig(&ServiceConfig{
+				DDNS:             d,
+				RetryInterval:    conf.RetryInterval,
+				MaxRetryInterval: conf.MaxRetryInterval,
+				FailureThreshold: conf.FailureThreshold,
+				Notifiers:        notifiers,
+				Redetect:         changed,
+			})
+			if changed {
+				redetect = append(redetect, service)
+			}
+			services = append(services, service)
 			continue
 		}
-		daemon.services = append(daemon.services, service)
+		service := daemon.newService(d, conf)
+		if service == nil {
+			continue
+		}
+		utility.Infof("Start updating the dynamic domain name record '%s'.", service.key)
+		daemon.start(service)
+		if ips := daemon.addresses(service); len(ips) > 0 {
+			service.PostUpdate(ips...)
+		}
+		services = append(services, service)
 	}
 
-	return nil
+	for key, service := range running {
+		utility.Infof("Stop updating the dynamic domain name record '%s'.", key)
+		service.cancel()
+	}
+
+	daemon.services = services
+	daemon.config.Prefix = conf.Prefix
+	daemon.config.Discovery = conf.Discovery
+	daemon.config.CheckInterval = conf.CheckInterval
+	daemon.config.RetryInterval = conf.RetryInterval
+	daemon.config.MaxRetryInterval = conf.MaxRetryInterval
+	daemon.config.ReconcileInterval = conf.ReconcileInterval
+	daemon.config.FailureThreshold = conf.FailureThreshold
+	daemon.config.Notifiers = conf.Notifiers
+	daemon.config.DynDNS = conf.DynDNS
+	daemon.config.DomainList = conf.DomainList
+	utility.Info("The configuration has been reloaded.")
+
+	if len(redetect) > 0 {
+		// the new sources are refreshed by the check, a new failover posts the
+		// address of its candidate once it has checked them
+		daemon.doCheck()
+		for _, service := range redetect {
+			if ips := daemon.addresses(service); len(ips) > 0 {
+				service.PostUpdate(ips...)
+			}
+		}
+	}
+}
+
+// call runs fn on the main loop of the daemon and waits for it to return.
+func (daemon *Daemon) call(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	select {
+	case daemon.control <- func() { fn(); close(done) }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status returns the detected IP addresses and the state of every record,
+// it must be called on the main loop.
+func (daemon *Daemon) Status() *DaemonStatus {
+	status := &DaemonStatus{
+		Addresses: []AddressStatus{
+			{
+				Type:    "A",
+				IP:      daemon.ipv4.Current().String(),
+				Changes: daemon.ipv4.Changes(),
+				Sources: daemon.ipv4.Sources(),
+			},
+			{
+				Type:    "AAAA",
+				IP:      daemon.ipv6.Current().String(),
+				Changes: daemon.ipv6.Changes(),
+				Sources: daemon.ipv6.Sources(),
+			},
+		},
+		Records: []RecordStatus{},
+	}
+	if daemon.prefix != nil {
+		status.Addresses = append(status.Addresses, AddressStatus{
+			Type:    "prefix",
+			IP:      daemon.prefix.Current().String(),
+			Changes: daemon.prefix.Changes(),
+			Sources: daemon.prefix.Sources(),
+		})
+	}
+	for _, service := range daemon.services {
+		status.Records = append(status.Records, service.Status())
+	}
+	return status
 }
 
 func (daemon *Daemon) doCheck() {
-	if ip, changed := daemon.ipv4.Refresh(); changed {
+	if daemon.dyndns != nil {
+		// the IP addresses are submitted by the dyndns2 clients
+		return
+	}
+	if ip, changed := daemon.ipv4.Refresh(daemon.ctx); changed {
 		utility.Infof("Detected that the IPv4 address(%s) has changed, preparing to update the domain name record...", ip.String())
-		for _, d := range daemon.services {
-			if d.record.Type != nil && *d.record.Type == "A" {
-				d.IpAddrChan <- &ip
-			}
-		}
+		daemon.postUpdate(daemon.ipv4, ip)
 	} else {
 		utility.Debug("IPv4 addresses have not changed, no need to update domain name records.")
 	}
 
-	if ip, changed := daemon.ipv6.Refresh(); changed {
+	if ip, changed := daemon.ipv6.Refresh(daemon.ctx); changed {
 		utility.Infof("Detected that the IPv6 address(%s) has changed, preparing to update the domain name record...", ip.String())
-		for _, d := range daemon.services {
-			if d.record.Type != nil && *d.record.Type == "AAAA" {
-				d.IpAddrChan <- &ip
-			}
-		}
+		daemon.postUpdate(daemon.ipv6, ip)
 	} else {
 		utility.Debug("IPv6 addresses have not changed, no need to update domain name records.")
 	}
+
+	if daemon.prefix != nil {
+		if ip, changed := daemon.prefix.Refresh(daemon.ctx); changed {
+			utility.Infof("Detected that the delegated IPv6 prefix(%s) has changed, preparing to update the domain name record...", ip.String())
+			daemon.postUpdate(daemon.prefix, ip)
+		} else {
+			utility.Debug("The delegated IPv6 prefix has not changed, no need to update domain name records.")
+		}
+	}
+
+	daemon.checkSources()
+	daemon.doDiscover(daemon.ctx)
+}
+
+// doDiscover publishes the records of the hosts found on the LAN, and stops
+// publishing the hosts that have not been seen for a while.
+func (daemon *Daemon) doDiscover(ctx context.Context) {
+	conf := daemon.config.Discovery
+	if conf == nil {
+		return
+	}
+	hosts, err := DiscoverHosts(ctx, conf)
+	if err != nil {
+		utility.Errorf("Failed to discover the hosts of the LAN: %s", err.Error())
+		return
+	}
+
+	now := time.Now()
+	for _, h := range hosts {
+		d := conf.entry(h.Hostname, daemon.config)
+		key := d.Key()
+		host, ok := daemon.discovered[key]
+		if !ok {
+			if daemon.hasService(key) {
+				utility.Debugf("The discovered host '%s' is already configured as '%s'.", h.Hostname, key)
+				continue
+			}
+			service := daemon.newService(d, daemon.config)
+			if service == nil {
+				continue
+			}
+			service.discovered = true
+			utility.Infof("Discovered the host '%s', start updating the dynamic domain name record '%s'.", h.Hostname, key)
+			if daemon.ctx != nil {
+				daemon.start(service)
+			}
+			daemon.services = append(daemon.services, service)
+			host = &discoveredHost{service: service}
+			daemon.discovered[key] = host
+		}
+		host.seen = now
+		if !host.ip.Equal(h.IP) {
+			host.ip = h.IP
+			host.service.PostUpdate(h.IP)
+		}
+	}
+
+	for key, host := range daemon.discovered {
+		if now.Sub(host.seen) < conf.StaleAfter*time.Second {
+			continue
+		}
+		delete(daemon.discovered, key)
+		daemon.removeService(host.service)
+		if conf.RemoveStale {
+			utility.Infof("The host of '%s' has not been seen since %s, its record will be removed.", key, host.seen.Format(time.RFC3339))
+			host.service.PostRemove()
+		} else {
+			utility.Infof("The host of '%s' has not been seen since %s, stop updating its record.", key, host.seen.Format(time.RFC3339))
+			host.service.cancel()
+		}
+	}
+}
+
+func (daemon *Daemon) hasService(key string) bool {
+	for _, service := range daemon.services {
+		if service.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (daemon *Daemon) removeService(service *UpdateService) {
+	for i, s := range daemon.services {
+		if s == service {
+			daemon.services = append(daemon.services[:i], daemon.services[i+1:]...)
+			return
+		}
+	}
+}
+
+// postUpdate posts the IP address to the services following the detector.
+func (daemon *Daemon) postUpdate(detector ExternalIP, ip net.IP) {
+	for _, d := range daemon.services {
+		if !d.discovered && len(d.sources) == 0 && d.failover == nil && daemon.detector(d) == detector {
+			d.PostUpdate(ip)
+		}
+	}
+}
+
+func (daemon *Daemon) doReconcile() {
+	utility.Debug("Reconciling dynamic domain name records with DNS...")
+	for _, d := range daemon.services {
+		if ips := daemon.addresses(d); len(ips) > 0 {
+			d.PostReconcile(ips...)
+		}
+	}
 }
 
 func (daemon *Daemon) Run() {
 
 	sigs := make(chan os.Signal, 1)
 	defer close(sigs)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	ctx, cancel := context.WithCancel(context.Background())
-	var wg sync.WaitGroup
+	daemon.ctx = ctx
 	for _, d := range daemon.services {
-		wg.Add(1)
-		go d.Routine(ctx, &wg)
+		daemon.start(d)
+	}
+
+	srv := &server{daemon: daemon}
+	if address := tea.StringValue(daemon.config.Listen); address != "" {
+		if listener, err := Listen(address); err != nil {
+			utility.Errorf("Failed to listen on '%s', the status API is disabled: %s", address, err.Error())
+		} else {
+			utility.Infof("The status API is listening on '%s'.", address)
+			srv.serve(listener)
+		}
+	}
+	if address := tea.StringValue(daemon.config.MetricsListen); address != "" {
+		if listener, err := Listen(address); err != nil {
+			utility.Errorf("Failed to listen on '%s', the metrics are disabled: %s", address, err.Error())
+		} else {
+			utility.Infof("The metrics are exposed on '%s/metrics'.", address)
+			srv.serveMetrics(listener)
+		}
+	}
+
+	if daemon.dyndns != nil {
+		srv.serveDynDNS(daemon.dyndns)
+	}
+
+	var ticker *time.Ticker
+	var reconcile <-chan time.Time
+	resetReconcile := func() {
+		if ticker != nil {
+			ticker.Stop()
+			ticker, reconcile = nil, nil
+		}
+		if daemon.config.ReconcileInterval > 0 {
+			ticker = time.NewTicker(daemon.config.ReconcileInterval * time.Second)
+			reconcile = ticker.C
+		}
+	}
+	if daemon.config.ReconcileInterval > 0 {
+		daemon.doCheck()
+		daemon.doReconcile()
 	}
+	resetReconcile()
+
+	check := time.NewTicker(daemon.config.CheckInterval * time.Second)
+	defer check.Stop()
+
+	// ping the systemd watchdog from the main loop, so that a stuck loop gets
+	// the daemon restarted
+	var watchdog <-chan time.Time
+	if interval := sdWatchdogInterval(); interval > 0 {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+	daemon.notifySystemd("READY=1")
+	daemon.notifyStatus()
 
 	loop := true
 	for loop {
 		select {
 		case s := <-sigs:
 			utility.Infof("System signal: %s", s.String())
-			loop = false
-		case <-time.After(daemon.config.CheckInterval * time.Second):
+			if s == syscall.SIGHUP {
+				daemon.notifySystemd(sdReloading())
+				daemon.doReload()
+				check.Reset(daemon.config.CheckInterval * time.Second)
+				resetReconcile()
+				daemon.notifySystemd("READY=1")
+				daemon.notifyStatus()
+			} else {
+				loop = false
+			}
+		case <-check.C:
 			daemon.doCheck()
+			daemon.notifyStatus()
+		case <-watchdog:
+			daemon.notifySystemd("WATCHDOG=1")
+		case <-reconcile:
+			daemon.doReconcile()
+		case fn := <-daemon.control:
+			fn()
 		}
 	}
 
+	daemon.notifySystemd("STOPPING=1")
+	srv.shutdown()
+
+	if ticker != nil {
+		ticker.Stop()
+	}
 	cancel()
-	wg.Wait()
+	daemon.wg.Wait()
+	for _, notifiers := range daemon.retired {
+		notifiers.Wait()
+	}
+	daemon.notifiers.Wait()
 
 	utility.Info("Daemon exit safely!")
 }
 
+// OnceResult is the outcome of a record in a one-shot run.
+type OnceResult struct {
+	Key    string
+	Value  string
+	Result SyncResult
+	Error  string
+}
+
+// RunOnce detects the IP addresses once and publishes every record
+// synchronously, giving up on the records not done within the timeout. The
+// timeout bounds the whole run: the discovery, the detection of the
+// addresses, the updates and the notifications.
+func (daemon *Daemon) RunOnce(timeout time.Duration) []OnceResult {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	daemon.doDiscover(ctx)
+
+	// there are no retries in a one-shot run, the next run is the retry
+	for _, service := range daemon.services {
+		service.backoff.Base = 0
+	}
+
+	// detect every address the records follow once
+	refreshed := map[ExternalIP]bool{}
+	refresh := func(detector ExternalIP) {
+		if !refreshed[detector] {
+			refreshed[detector] = true
+			detector.Refresh(ctx)
+		}
+	}
+	for _, service := range daemon.services {
+		if service.discovered || ctx.Err() != nil {
+			continue
+		}
+		if service.failover != nil {
+			service.failover.refresh(ctx)
+		} else if len(service.sources) > 0 {
+			for _, source := range service.sources {
+				refresh(source)
+			}
+		} else {
+			refresh(daemon.detector(service))
+		}
+	}
+
+	results := make([]OnceResult, len(daemon.services))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for i, service := range daemon.services {
+		results[i] = OnceResult{Key: service.key, Result: SYNC_TIMEOUT}
+
+		ips := daemon.addresses(service)
+		if len(ips) == 0 {
+			results[i].Result = SYNC_FAILED
+			results[i].Error = "the IP address could not be detected"
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, service *UpdateService, ips []net.IP) {
+			defer wg.Done()
+			result := service.Sync(ips)
+			status := service.Status()
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			results[i].Result = result
+			results[i].Value = strings.Join(service.values(ips), ",")
+			if result == SYNC_FAILED {
+				results[i].Error = status.LastError
+			}
+		}(i, service, ips)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		if !daemon.notifiers.WaitContext(ctx) {
+			utility.Errorf("Not all notifications were sent within %s.", timeout)
+		}
+	case <-ctx.Done():
+		utility.Errorf("Not all domain name records were updated within %s.", timeout)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	return append([]OnceResult(nil), results...)
+}
+
 func NewDaemon(conf *Config) (*Daemon, error) {
-	daemon := &Daemon{}
+	daemon := &Daemon{
+		control:    make(chan func()),
+		discovered: map[string]*discoveredHost{},
+	}
 	if err := daemon.init(conf); err != nil {
 		return nil, err
 	}
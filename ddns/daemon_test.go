@@ -0,0 +1,37 @@
+package ddns
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestRunOnceTimeout runs once against providers of the address that never
+// answer, the timeout bounds the detection as well as the updates.
+func TestRunOnceTimeout(t *testing.T) {
+	stuck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Minute):
+		}
+	}))
+	defer stuck.Close()
+
+	s := testService(t, "www", "A")
+	daemon := &Daemon{
+		config:   &Config{},
+		services: []*UpdateService{s},
+		ipv4:     NewExternalIPv4([]string{stuck.URL, stuck.URL + "/ip"}),
+		ipv6:     NewExternalIPv6(nil),
+	}
+
+	begin := time.Now()
+	results := daemon.RunOnce(300 * time.Millisecond)
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Fatalf("the run took %s", elapsed)
+	}
+	if len(results) != 1 || results[0].Result != SYNC_FAILED {
+		t.Errorf("got results %+v", results)
+	}
+}
@@ -0,0 +1,265 @@
+package ddns
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+)
+
+// DiscoveryConfig publishes an AAAA record "<host>.<Subdomain>" for every host
+// of the LAN with a DHCP host name. The addresses are read from the DHCPv6
+// leases, and from the neighbour table of Interface for the hosts that only
+// use SLAAC. The records of the hosts not seen for StaleAfter seconds are no
+// longer updated, and deleted from DNS if RemoveStale is set.
+type DiscoveryConfig struct {
+	Interface   *string       `json:"Interface"`
+	LeaseFiles  []string      `json:"LeaseFiles"`
+	Subdomain   *string       `json:"Subdomain"`
+	DomainName  *string       `json:"DomainName"`
+	TTL         *int64        `json:"TTL"`
+	RemoveStale bool          `json:"RemoveStale"`
+	StaleAfter  time.Duration `json:"StaleAfter"`
+}
+
+// DiscoveredHost is a LAN host and the address published for it.
+type DiscoveredHost struct {
+	Hostname string
+	IP       net.IP
+}
+
+// entry returns the DDNS entry of a discovered host.
+func (conf *DiscoveryConfig) entry(hostname string, parent *Config) *DDNS {
+	rr := hostname
+	if subdomain := tea.StringValue(conf.Subdomain); subdomain != "" {
+		rr += "." + subdomain
+	}
+	return &DDNS{
+		AccessKeyId:     parent.AccessKeyId,
+		AccessKeySecret: parent.AccessKeySecret,
+		DomainName:      utility.DefaultIfEmpty(conf.DomainName, parent.DomainName),
+		RR:              tea.String(rr),
+		Type:            tea.String("AAAA"),
+		TTL:             conf.TTL,
+	}
+}
+
+// leases are the host names and addresses read from DHCP lease files.
+type leases struct {
+	names     map[string]string   // MAC address => host name
+	addresses map[string][]net.IP // host name => DHCPv6 addresses
+}
+
+// hostLabel turns a DHCP host name into a DNS label, or returns "" if there is
+// nothing left of it.
+func hostLabel(hostname string) string {
+	label := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, strings.SplitN(hostname, ".", 2)[0])
+	return strings.Trim(label, "-")
+}
+
+// read parses a lease file of dnsmasq or odhcpd, the format is detected
+// line by line.
+func (l *leases) read(fileName string) error {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) >= 8 && fields[0] == "#":
+			// odhcpd: # iface duid iaid hostname valid assigned length address/length...
+			hostname := hostLabel(fields[4])
+			if hostname == "" {
+				continue
+			}
+			if fields[3] == "ipv4" {
+				if mac, err := net.ParseMAC(fields[2]); err == nil {
+					l.names[mac.String()] = hostname
+				}
+				continue
+			}
+			for _, address := range fields[8:] {
+				if ip, _, err := net.ParseCIDR(address); err == nil && ip.To4() == nil {
+					l.addresses[hostname] = append(l.addresses[hostname], ip)
+				}
+			}
+		case len(fields) >= 4 && fields[0] != "duid":
+			// dnsmasq: expiry mac ipv4 hostname clientid, or expiry iaid ipv6 hostname duid
+			hostname := hostLabel(fields[3])
+			ip := net.ParseIP(fields[2])
+			if hostname == "" || ip == nil {
+				continue
+			}
+			if ip.To4() == nil {
+				l.addresses[hostname] = append(l.addresses[hostname], ip)
+			} else if mac, err := net.ParseMAC(fields[1]); err == nil {
+				l.names[mac.String()] = hostname
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// onLinkPrefixes reads the on-link IPv6 routes of the interface from
+// /proc/net/ipv6_route.
+func onLinkPrefixes(iface string) ([]*net.IPNet, error) {
+	data, err := os.ReadFile("/proc/net/ipv6_route")
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixes []*net.IPNet
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// dest dest_len src src_len next_hop metric refcnt use flags iface
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 10 || fields[9] != iface || strings.Trim(fields[4], "0") != "" {
+			continue
+		}
+		dest, err := hex.DecodeString(fields[0])
+		if err != nil || len(dest) != net.IPv6len {
+			continue
+		}
+		length, err := strconv.ParseUint(fields[1], 16, 8)
+		if err != nil || length == 0 || length > 64 {
+			continue
+		}
+		if ip := net.IP(dest); ip.IsGlobalUnicast() {
+			prefixes = append(prefixes, &net.IPNet{IP: ip, Mask: net.CIDRMask(int(length), 128)})
+		}
+	}
+	return prefixes, scanner.Err()
+}
+
+type neighbour struct {
+	ip  net.IP
+	mac net.HardwareAddr
+}
+
+// neighbours reads the IPv6 neighbour table of the interface.
+func neighbours(ctx context.Context, iface string) ([]neighbour, error) {
+	output, err := exec.CommandContext(ctx, "ip", "-6", "neigh", "show", "dev", iface).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the neighbour table: %s", err.Error())
+	}
+
+	var list []neighbour
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		// 2001:db8::5 lladdr 52:54:00:12:34:56 [router] REACHABLE
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[1] != "lladdr" {
+			continue
+		}
+		if state := fields[len(fields)-1]; state == "FAILED" || state == "INCOMPLETE" {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		mac, err := net.ParseMAC(fields[2])
+		if ip == nil || err != nil {
+			continue
+		}
+		list = append(list, neighbour{ip: ip, mac: mac})
+	}
+	return list, scanner.Err()
+}
+
+// candidate is an address of a host, the preferred one is published.
+type candidate struct {
+	ip     net.IP
+	leased bool
+	eui64  bool
+}
+
+func (c *candidate) less(other *candidate) bool {
+	if c.leased != other.leased {
+		return c.leased
+	}
+	// a unique local address is only published if the host has no other one
+	if private := c.ip.IsPrivate(); private != other.ip.IsPrivate() {
+		return !private
+	}
+	// the EUI-64 address is stable, unlike the temporary addresses
+	if c.eui64 != other.eui64 {
+		return c.eui64
+	}
+	return bytes.Compare(c.ip, other.ip) < 0
+}
+
+// DiscoverHosts maps the host names of the LAN to their current global IPv6
+// address.
+func DiscoverHosts(ctx context.Context, conf *DiscoveryConfig) ([]*DiscoveredHost, error) {
+	l := &leases{names: map[string]string{}, addresses: map[string][]net.IP{}}
+	for _, fileName := range conf.LeaseFiles {
+		if err := l.read(fileName); err != nil {
+			return nil, err
+		}
+	}
+
+	candidates := map[string][]*candidate{}
+	for hostname, addresses := range l.addresses {
+		for _, ip := range addresses {
+			if ip.IsGlobalUnicast() {
+				candidates[hostname] = append(candidates[hostname], &candidate{ip: ip, leased: true})
+			}
+		}
+	}
+
+	if iface := tea.StringValue(conf.Interface); iface != "" {
+		prefixes, err := onLinkPrefixes(iface)
+		if err != nil {
+			return nil, err
+		}
+		list, err := neighbours(ctx, iface)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range list {
+			hostname, ok := l.names[n.mac.String()]
+			if !ok || !n.ip.IsGlobalUnicast() {
+				continue
+			}
+			for _, prefix := range prefixes {
+				if prefix.Contains(n.ip) {
+					id, _ := eui64(n.mac)
+					candidates[hostname] = append(candidates[hostname], &candidate{ip: n.ip, eui64: bytes.Equal(n.ip[8:], id)})
+					break
+				}
+			}
+		}
+	} else if len(conf.LeaseFiles) == 0 {
+		return nil, errors.New("either the Interface or the LeaseFiles of the discovery must be specified")
+	}
+
+	hosts := make([]*DiscoveredHost, 0, len(candidates))
+	for hostname, list := range candidates {
+		sort.Slice(list, func(i, j int) bool { return list[i].less(list[j]) })
+		hosts = append(hosts, &DiscoveredHost{Hostname: hostname, IP: list[0].ip})
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Hostname < hosts[j].Hostname })
+	return hosts, nil
+}
@@ -0,0 +1,206 @@
+package ddns
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/kdiot/alidns-console/utility"
+)
+
+// The responses of the dyndns2 protocol.
+const (
+	DynDNSGood    = "good"
+	DynDNSNochg   = "nochg"
+	DynDNSBadauth = "badauth"
+	DynDNSNohost  = "nohost"
+	DynDNSNotfqdn = "notfqdn"
+	DynDNSBadip   = "badip"
+)
+
+// allows tells whether the user may update the host name.
+func (user *DynDNSUser) allows(hostname string) bool {
+	for _, h := range user.Hostnames {
+		if h == "*" || strings.EqualFold(strings.TrimSuffix(h, "."), hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostname returns the fully qualified name of the record managed by the service.
+func (s *UpdateService) hostname() string {
+	return s.fqdn
+}
+
+// authenticate returns the user matching the credentials, or nil. It must be
+// called on the main loop.
+func (daemon *Daemon) authenticate(username string, password string) *DynDNSUser {
+	if daemon.config.DynDNS == nil {
+		return nil
+	}
+	for _, user := range daemon.config.DynDNS.Users {
+		if user.Username == username &&
+			subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) == 1 {
+			return user
+		}
+	}
+	return nil
+}
+
+// submit pushes the IP addresses submitted by a dyndns2 client to the records
+// of the host name, and returns the dyndns2 response. It must be called on the
+// main loop.
+func (daemon *Daemon) submit(user *DynDNSUser, hostname string, ips []net.IP) string {
+	if !user.allows(hostname) {
+		return DynDNSNohost
+	}
+
+	found, changed := false, false
+	for _, service := range daemon.services {
+		if !strings.EqualFold(service.hostname(), hostname) {
+			continue
+		}
+		for _, ip := range ips {
+			if (ip.To4() != nil) != (service.recordType == "A") {
+				continue
+			}
+			found = true
+
+			// the same address is pushed again only if it failed for good, a
+			// pending retry will publish it anyway
+			status := service.Status()
+			if last := daemon.submitted[service.key]; last.Equal(ip) && (status.LastError == "" || status.NextRetry != nil) {
+				continue
+			}
+			utility.Infof("The dyndns2 client '%s' submitted the IP address(%s) of '%s'.", user.Username, ip.String(), service.key)
+			daemon.submitted[service.key] = ip
+			service.PostUpdate(ip)
+			changed = true
+		}
+	}
+
+	if !found {
+		return DynDNSNohost
+	}
+	addresses := make([]string, len(ips))
+	for i, ip := range ips {
+		addresses[i] = ip.String()
+	}
+	if changed {
+		return DynDNSGood + " " + strings.Join(addresses, ",")
+	}
+	return DynDNSNochg + " " + strings.Join(addresses, ",")
+}
+
+// handleNicUpdate implements the dyndns2 update request:
+// /nic/update?hostname=host1,host2&myip=ipv4,ipv6 with basic authentication.
+// The address of the client is used if myip is not given.
+func (srv *server) handleNicUpdate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="alidns"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, DynDNSBadauth)
+		return
+	}
+
+	query := r.URL.Query()
+	var hostnames []string
+	for _, hostname := range strings.Split(query.Get("hostname"), ",") {
+		if hostname = strings.TrimSuffix(strings.TrimSpace(hostname), "."); hostname != "" {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	if len(hostnames) == 0 {
+		fmt.Fprintln(w, DynDNSNotfqdn)
+		return
+	}
+
+	addresses := query.Get("myip")
+	if v6 := query.Get("myipv6"); v6 != "" {
+		addresses = strings.TrimPrefix(addresses+","+v6, ",")
+	}
+	if addresses == "" {
+		addresses, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	var ips []net.IP
+	for _, address := range strings.Split(addresses, ",") {
+		ip := net.ParseIP(strings.TrimSpace(address))
+		if ip == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, DynDNSBadip)
+			return
+		}
+		ips = append(ips, ip)
+	}
+
+	var user *DynDNSUser
+	var responses []string
+	err := srv.daemon.call(r.Context(), func() {
+		if user = srv.daemon.authenticate(username, password); user == nil {
+			return
+		}
+		for _, hostname := range hostnames {
+			responses = append(responses, srv.daemon.submit(user, hostname, ips))
+		}
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, "911")
+		return
+	}
+	if user == nil {
+		utility.Warningf("The dyndns2 client at '%s' failed to authenticate as '%s'.", r.RemoteAddr, username)
+		w.Header().Set("WWW-Authenticate", `Basic realm="alidns"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, DynDNSBadauth)
+		return
+	}
+	fmt.Fprintln(w, strings.Join(responses, "\n"))
+}
+
+// serveDynDNS serves the dyndns2 update requests on the listener.
+func (srv *server) serveDynDNS(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nic/update", srv.handleNicUpdate)
+	srv.start(listener, mux)
+}
+
+// Serve runs the daemon as a dyndns2 compatible update server: instead of
+// detecting the IP addresses, the records are updated with the addresses that
+// routers and other devices submit.
+func (daemon *Daemon) Serve() error {
+	conf := daemon.config.DynDNS
+	if conf == nil || conf.Listen == "" {
+		return errors.New("the Listen address of DynDNS must be specified in the configuration file")
+	}
+	if len(conf.Users) == 0 {
+		return errors.New("no user of DynDNS is configured")
+	}
+
+	listener, err := Listen(conf.Listen)
+	if err != nil {
+		return err
+	}
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to load the certificate of DynDNS: %s", err.Error())
+		}
+		listener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{cert}})
+	}
+	utility.Infof("The dyndns2 update server is listening on '%s'.", conf.Listen)
+
+	daemon.dyndns = listener
+	daemon.submitted = map[string]net.IP{}
+	daemon.Run()
+	return nil
+}
@@ -0,0 +1,326 @@
+package ddns
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HealthCheckConfig configures how the candidates of a failover record are
+// checked, Type is 'tcp' (connect to Port) or 'http' (GET Path, expecting one
+// of Status, any 2xx or 3xx status if not specified).
+type HealthCheckConfig struct {
+	Type     string        `json:"Type"`
+	Port     int           `json:"Port"`
+	Scheme   string        `json:"Scheme"`
+	Path     string        `json:"Path"`
+	Host     string        `json:"Host"`
+	Status   []int         `json:"Status"`
+	Insecure bool          `json:"Insecure"`
+	Timeout  time.Duration `json:"Timeout"`
+}
+
+// FailoverConfig keeps a record pointing at the first healthy candidate, the
+// primary first. A candidate is considered down after FailAfter failed checks
+// in a row, and up again after RecoverAfter successful ones. The candidates
+// are checked every Interval seconds.
+type FailoverConfig struct {
+	Candidates   []string          `json:"Candidates"`
+	Check        HealthCheckConfig `json:"Check"`
+	Interval     time.Duration     `json:"Interval"`
+	FailAfter    int               `json:"FailAfter"`
+	RecoverAfter int               `json:"RecoverAfter"`
+}
+
+// CandidateStatus is the health of a candidate of a failover record.
+type CandidateStatus struct {
+	Value     string     `json:"Value"`
+	Healthy   bool       `json:"Healthy"`
+	Successes int        `json:"Successes"`
+	Failures  int        `json:"Failures"`
+	LastError string     `json:"LastError,omitempty"`
+	Checked   *time.Time `json:"Checked,omitempty"`
+}
+
+// Checker checks the health of a candidate value.
+type Checker interface {
+	Check(ctx context.Context, ip net.IP) error
+}
+
+// TcpChecker succeeds if a TCP connection to the port can be established.
+type TcpChecker struct {
+	port string
+}
+
+func (c *TcpChecker) Check(ctx context.Context, ip net.IP) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), c.port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// HttpChecker succeeds if a GET request is answered with an expected status.
+type HttpChecker struct {
+	scheme string
+	port   string
+	path   string
+	host   string
+	status []int
+	client *http.Client
+}
+
+func (c *HttpChecker) Check(ctx context.Context, ip net.IP) error {
+	url := fmt.Sprintf("%s://%s%s", c.scheme, net.JoinHostPort(ip.String(), c.port), c.path)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if c.host != "" {
+		request.Host = c.host
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if len(c.status) == 0 {
+		if response.StatusCode >= 200 && response.StatusCode < 400 {
+			return nil
+		}
+	} else {
+		for _, status := range c.status {
+			if response.StatusCode == status {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("unexpected status '%s'", response.Status)
+}
+
+func NewChecker(conf *HealthCheckConfig) (Checker, error) {
+	switch conf.Type {
+	case "tcp":
+		if conf.Port <= 0 || conf.Port > 65535 {
+			return nil, errors.New("the Port of the tcp health check must be specified")
+		}
+		return &TcpChecker{port: strconv.Itoa(conf.Port)}, nil
+	case "http":
+		c := &HttpChecker{
+			scheme: conf.Scheme,
+			port:   strconv.Itoa(conf.Port),
+			path:   conf.Path,
+			host:   conf.Host,
+			status: conf.Status,
+		}
+		if c.scheme == "" {
+			c.scheme = "http"
+		} else if c.scheme != "http" && c.scheme != "https" {
+			return nil, fmt.Errorf("the Scheme '%s' of the http health check is illegal", conf.Scheme)
+		}
+		if conf.Port == 0 {
+			c.port = map[string]string{"http": "80", "https": "443"}[c.scheme]
+		}
+		if c.path == "" {
+			c.path = "/"
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{ServerName: conf.Host, InsecureSkipVerify: conf.Insecure}
+		c.client = &http.Client{
+			Transport: transport,
+			// the status of a redirection is the result of the check
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown health check type '%s'", conf.Type)
+	}
+}
+
+// candidate health, a candidate changes its state only after enough results
+// in a row, so that a single lost check does not switch the record.
+type health struct {
+	ip        net.IP
+	known     bool
+	healthy   bool
+	successes int
+	failures  int
+	lastError string
+	checked   time.Time
+}
+
+func (h *health) record(err error, failAfter int, recoverAfter int) {
+	h.checked = time.Now()
+	if err == nil {
+		h.successes++
+		h.failures = 0
+		h.lastError = ""
+	} else {
+		h.failures++
+		h.successes = 0
+		h.lastError = err.Error()
+	}
+
+	switch {
+	case !h.known:
+		// the first check decides the initial state
+		h.known, h.healthy = true, err == nil
+	case h.healthy && h.failures >= failAfter:
+		h.healthy = false
+	case !h.healthy && h.successes >= recoverAfter:
+		h.healthy = true
+	}
+}
+
+// monitor checks the candidates of a failover record, and posts the value to
+// publish to the service when the first healthy candidate changes.
+type monitor struct {
+	conf         *FailoverConfig
+	service      *UpdateService
+	checker      Checker
+	interval     time.Duration
+	timeout      time.Duration
+	failAfter    int
+	recoverAfter int
+	candidates   []*health
+	mutex        sync.Mutex
+	current      net.IP
+	cancel       context.CancelFunc
+}
+
+func newMonitor(service *UpdateService, conf *FailoverConfig) (*monitor, error) {
+	checker, err := NewChecker(&conf.Check)
+	if err != nil {
+		return nil, err
+	}
+	m := &monitor{
+		conf:         conf,
+		service:      service,
+		checker:      checker,
+		interval:     conf.Interval,
+		timeout:      conf.Check.Timeout,
+		failAfter:    conf.FailAfter,
+		recoverAfter: conf.RecoverAfter,
+	}
+	if m.interval <= 0 {
+		m.interval = 10
+	}
+	if m.timeout <= 0 {
+		m.timeout = 5
+	}
+	if m.failAfter <= 0 {
+		m.failAfter = 3
+	}
+	if m.recoverAfter <= 0 {
+		m.recoverAfter = 3
+	}
+	for _, value := range conf.Candidates {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("the failover candidate '%s' is not an IP address", value)
+		}
+		m.candidates = append(m.candidates, &health{ip: ip})
+	}
+	if len(m.candidates) == 0 {
+		return nil, errors.New("the failover record has no candidate")
+	}
+	return m, nil
+}
+
+// Current returns the value the record should hold, nil until the candidates
+// have been checked.
+func (m *monitor) Current() net.IP {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.current
+}
+
+// check checks all the candidates once, and returns the first healthy one. The
+// current value is kept if no candidate is healthy.
+func (m *monitor) check(ctx context.Context) net.IP {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout*time.Second)
+	defer cancel()
+
+	errs := make([]error, len(m.candidates))
+	var wg sync.WaitGroup
+	for i, c := range m.candidates {
+		wg.Add(1)
+		go func(i int, ip net.IP) {
+			defer wg.Done()
+			errs[i] = m.checker.Check(ctx, ip)
+		}(i, c.ip)
+	}
+	wg.Wait()
+
+	statuses := make([]CandidateStatus, len(m.candidates))
+	for i, c := range m.candidates {
+		c.record(errs[i], m.failAfter, m.recoverAfter)
+		checked := c.checked
+		statuses[i] = CandidateStatus{
+			Value:     c.ip.String(),
+			Healthy:   c.healthy,
+			Successes: c.successes,
+			Failures:  c.failures,
+			LastError: c.lastError,
+			Checked:   &checked,
+		}
+	}
+	m.service.setStatus(func(status *RecordStatus) { status.Candidates = statuses })
+
+	for _, c := range m.candidates {
+		if c.healthy {
+			return c.ip
+		}
+	}
+	return m.Current()
+}
+
+// refresh checks the candidates once, and reports whether the value the
+// record should hold has changed.
+func (m *monitor) refresh(ctx context.Context) (net.IP, bool) {
+	ip := m.check(ctx)
+	m.mutex.Lock()
+	previous := m.current
+	m.current = ip
+	m.mutex.Unlock()
+
+	switch {
+	case ip == nil:
+		m.service.log.Warningf("No candidate of the dynamic domain name record '%s' is healthy.", m.service.key)
+	case previous == nil:
+		m.service.log.Infof("The dynamic domain name record '%s' points at the healthy candidate %s.", m.service.key, ip.String())
+	case !ip.Equal(previous):
+		m.service.log.Warningf("Failing over the dynamic domain name record '%s' from %s to %s.", m.service.key, previous.String(), ip.String())
+	default:
+		return ip, false
+	}
+	return ip, ip != nil
+}
+
+// Routine checks the candidates every interval until the context is done.
+func (m *monitor) Routine(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(m.interval * time.Second)
+	defer ticker.Stop()
+	for {
+		if ip, changed := m.refresh(ctx); changed {
+			m.service.PostUpdate(ip)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func checkContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// serverAddress returns the IP address and the port of a test server.
+func serverAddress(t *testing.T, server *httptest.Server) (net.IP, int) {
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+	return net.ParseIP(u.Hostname()), port
+}
+
+func TestTcpChecker(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	c, err := NewChecker(&HealthCheckConfig{Type: "tcp", Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip := net.ParseIP("127.0.0.1")
+	if err := c.Check(checkContext(t), ip); err != nil {
+		t.Errorf("the listening port failed the check: %s", err.Error())
+	}
+
+	listener.Close()
+	if err := c.Check(checkContext(t), ip); err == nil {
+		t.Error("the closed port passed the check")
+	}
+}
+
+func TestHttpChecker(t *testing.T) {
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		case "/maintenance":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	ip, port := serverAddress(t, server)
+
+	for _, c := range []struct {
+		conf HealthCheckConfig
+		ok   bool
+	}{
+		{HealthCheckConfig{Path: "/health"}, true},
+		// a redirection is not followed, its status is the result
+		{HealthCheckConfig{Path: "/moved"}, true},
+		{HealthCheckConfig{Path: "/maintenance"}, false},
+		{HealthCheckConfig{Path: "/missing"}, false},
+		{HealthCheckConfig{Path: "/missing", Status: []int{404}}, true},
+		{HealthCheckConfig{Path: "/health", Status: []int{204}}, false},
+	} {
+		c.conf.Type, c.conf.Port = "http", port
+		checker, err := NewChecker(&c.conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checker.Check(checkContext(t), ip); (err == nil) != c.ok {
+			t.Errorf("%s with status %v: got error %v, want success %v", c.conf.Path, c.conf.Status, err, c.ok)
+		}
+	}
+
+	checker, _ := NewChecker(&HealthCheckConfig{Type: "http", Port: port, Host: "www.example.com"})
+	checker.Check(checkContext(t), ip)
+	if host != "www.example.com" {
+		t.Errorf("got Host '%s', want the configured one", host)
+	}
+}
+
+func TestHttpsChecker(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	ip, port := serverAddress(t, server)
+
+	checker, _ := NewChecker(&HealthCheckConfig{Type: "http", Scheme: "https", Port: port})
+	if err := checker.Check(checkContext(t), ip); err == nil {
+		t.Error("the self-signed certificate passed the check")
+	}
+
+	checker, _ = NewChecker(&HealthCheckConfig{Type: "http", Scheme: "https", Port: port, Insecure: true})
+	if err := checker.Check(checkContext(t), ip); err != nil {
+		t.Errorf("the insecure check failed: %s", err.Error())
+	}
+}
+
+func TestHealthHysteresis(t *testing.T) {
+	fail := errors.New("connection refused")
+	h := &health{ip: net.ParseIP("203.0.113.10")}
+
+	for i, c := range []struct {
+		err     error
+		healthy bool
+	}{
+		// the first check decides the initial state
+		{nil, true},
+		{fail, true},
+		{fail, true},
+		// a success resets the failures
+		{nil, true},
+		{fail, true},
+		{fail, true},
+		{fail, false},
+		{nil, false},
+		{fail, false},
+		{nil, false},
+		{nil, false},
+		{nil, true},
+	} {
+		h.record(c.err, 3, 3)
+		if h.healthy != c.healthy {
+			t.Fatalf("check #%d (error %v): healthy %v, want %v", i+1, c.err, h.healthy, c.healthy)
+		}
+	}
+	if h.lastError != "" || h.successes != 3 || h.failures != 0 {
+		t.Errorf("got %+v", h)
+	}
+}
+
+func TestHealthInitiallyDown(t *testing.T) {
+	h := &health{ip: net.ParseIP("203.0.113.10")}
+	h.record(errors.New("timeout"), 3, 2)
+	if !h.known || h.healthy || h.lastError != "timeout" {
+		t.Fatalf("a first failed check is not down: %+v", h)
+	}
+	h.record(nil, 3, 2)
+	if h.healthy {
+		t.Error("a single success recovered the candidate")
+	}
+	h.record(nil, 3, 2)
+	if !h.healthy {
+		t.Error("the candidate did not recover after RecoverAfter successes")
+	}
+}
@@ -0,0 +1,63 @@
+package ddns
+
+import (
+	"net"
+	"sync"
+)
+
+// mailbox holds the events pending for an UpdateService. Posting never blocks,
+// and events of the same kind coalesce so that a slow record only ever sees the
+// latest IP address once it catches up.
+type mailbox struct {
+	mutex     sync.Mutex
+	wake      chan struct{}
+	update    []net.IP
+	force     bool
+	reconcile []net.IP
+	config    *ServiceConfig
+	retry     uint64
+	remove    bool
+}
+
+// letters is a snapshot of the events taken out of the mailbox.
+type letters struct {
+	update    []net.IP
+	force     bool
+	reconcile []net.IP
+	config    *ServiceConfig
+	retry     uint64
+	remove    bool
+}
+
+func newMailbox() *mailbox {
+	return &mailbox{wake: make(chan struct{}, 1)}
+}
+
+func (m *mailbox) post(fn func(m *mailbox)) {
+	m.mutex.Lock()
+	fn(m)
+	m.mutex.Unlock()
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+		// the service has already been woken up and will see the new event
+	}
+}
+
+// take empties the mailbox.
+func (m *mailbox) take() letters {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	l := letters{
+		update:    m.update,
+		force:     m.force,
+		reconcile: m.reconcile,
+		config:    m.config,
+		retry:     m.retry,
+		remove:    m.remove,
+	}
+	m.update, m.force, m.reconcile, m.config, m.retry, m.remove = nil, false, nil, nil, 0, false
+	return l
+}
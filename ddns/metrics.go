@@ -0,0 +1,110 @@
+package ddns
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	w io.Writer
+}
+
+func (m *metricsWriter) header(name string, kind string, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	var builder strings.Builder
+	builder.WriteString(name)
+	if len(labels) > 0 {
+		builder.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				builder.WriteByte(',')
+			}
+			builder.WriteString(labels[i])
+			builder.WriteString("=")
+			builder.WriteString(strconv.Quote(labels[i+1]))
+		}
+		builder.WriteByte('}')
+	}
+	builder.WriteByte(' ')
+	builder.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	builder.WriteByte('\n')
+	io.WriteString(m.w, builder.String())
+}
+
+// WriteMetrics writes the status of the daemon as Prometheus metrics.
+func (status *DaemonStatus) WriteMetrics(w io.Writer) {
+	m := &metricsWriter{w: w}
+	now := time.Now()
+
+	m.header("alidns_ddns_ip_changes_total", "counter", "Number of changes of the detected external IP address.")
+	for _, address := range status.Addresses {
+		m.sample("alidns_ddns_ip_changes_total", float64(address.Changes), "type", address.Type)
+	}
+
+	m.header("alidns_ddns_provider_requests_total", "counter", "Number of queries of the IP address provider.")
+	for _, address := range status.Addresses {
+		for _, source := range address.Sources {
+			m.sample("alidns_ddns_provider_requests_total", float64(source.Requests), "type", address.Type, "source", source.Source)
+		}
+	}
+
+	m.header("alidns_ddns_provider_failures_total", "counter", "Number of failed queries of the IP address provider.")
+	for _, address := range status.Addresses {
+		for _, source := range address.Sources {
+			m.sample("alidns_ddns_provider_failures_total", float64(source.Failures), "type", address.Type, "source", source.Source)
+		}
+	}
+
+	m.header("alidns_ddns_provider_latency_seconds", "summary", "Latency of the queries of the IP address provider.")
+	for _, address := range status.Addresses {
+		for _, source := range address.Sources {
+			m.sample("alidns_ddns_provider_latency_seconds_sum", source.LatencySum, "type", address.Type, "source", source.Source)
+			m.sample("alidns_ddns_provider_latency_seconds_count", float64(source.Requests), "type", address.Type, "source", source.Source)
+		}
+	}
+
+	m.header("alidns_ddns_update_attempts_total", "counter", "Number of attempts to update the domain name record.")
+	for _, record := range status.Records {
+		m.sample("alidns_ddns_update_attempts_total", float64(record.Attempts), "record", record.Key)
+	}
+
+	m.header("alidns_ddns_update_successes_total", "counter", "Number of successful updates of the domain name record.")
+	for _, record := range status.Records {
+		m.sample("alidns_ddns_update_successes_total", float64(record.Successes), "record", record.Key)
+	}
+
+	m.header("alidns_ddns_update_failures_total", "counter", "Number of failed updates of the domain name record.")
+	for _, record := range status.Records {
+		m.sample("alidns_ddns_update_failures_total", float64(record.Failures), "record", record.Key)
+	}
+
+	m.header("alidns_ddns_update_pending", "gauge", "Whether a retry of the domain name record update is pending.")
+	for _, record := range status.Records {
+		pending := 0.0
+		if record.NextRetry != nil {
+			pending = 1
+		}
+		m.sample("alidns_ddns_update_pending", pending, "record", record.Key)
+	}
+
+	m.header("alidns_ddns_last_success_timestamp_seconds", "gauge", "Time of the last successful update of the domain name record.")
+	for _, record := range status.Records {
+		if record.LastSuccess != nil {
+			m.sample("alidns_ddns_last_success_timestamp_seconds", float64(record.LastSuccess.Unix()), "record", record.Key)
+		}
+	}
+
+	m.header("alidns_ddns_seconds_since_last_success", "gauge", "Seconds since the last successful update of the domain name record.")
+	for _, record := range status.Records {
+		if record.LastSuccess != nil {
+			m.sample("alidns_ddns_seconds_since_last_success", now.Sub(*record.LastSuccess).Seconds(), "record", record.Key)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package ddns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+)
+
+const (
+	EventChange  = "change"
+	EventSuccess = "success"
+	EventFailure = "failure"
+)
+
+// Event describes what happened to a dynamic domain name record.
+type Event struct {
+	Event      string    `json:"Event"`
+	Key        string    `json:"Key"`
+	DomainName string    `json:"DomainName"`
+	RR         string    `json:"RR"`
+	Type       string    `json:"Type"`
+	OldIP      string    `json:"OldIP"`
+	NewIP      string    `json:"NewIP"`
+	Error      string    `json:"Error,omitempty"`
+	Failures   int       `json:"Failures"`
+	Time       time.Time `json:"Time"`
+}
+
+// NotifierConfig configures a notifier, Type is one of 'webhook', 'smtp' or 'exec'.
+type NotifierConfig struct {
+	Type      string            `json:"Type"`
+	Events    []string          `json:"Events"`
+	RateLimit time.Duration     `json:"RateLimit"`
+	URL       string            `json:"URL"`
+	Method    string            `json:"Method"`
+	Headers   map[string]string `json:"Headers"`
+	Body      string            `json:"Body"`
+	Host      string            `json:"Host"`
+	Username  string            `json:"Username"`
+	Password  string            `json:"Password"`
+	From      string            `json:"From"`
+	To        []string          `json:"To"`
+	Subject   string            `json:"Subject"`
+	Command   string            `json:"Command"`
+	Args      []string          `json:"Args"`
+}
+
+type Notifier interface {
+	Notify(event *Event) error
+}
+
+var templateFuncs = template.FuncMap{
+	// json encodes a value as a JSON string, for use in templated JSON bodies
+	"json": func(v interface{}) (string, error) {
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(v); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(buffer.String(), "\n"), nil
+	},
+}
+
+func render(tmpl *template.Template, event *Event) (string, error) {
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, event); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// WebhookNotifier sends the event to an HTTP endpoint. The body is a template
+// of the Event, the event itself is sent as JSON if no body is configured.
+type WebhookNotifier struct {
+	url     string
+	method  string
+	headers map[string]string
+	body    *template.Template
+	client  *http.Client
+}
+
+func (n *WebhookNotifier) Notify(event *Event) error {
+	var body []byte
+	if n.body != nil {
+		content, err := render(n.body, event)
+		if err != nil {
+			return err
+		}
+		body = []byte(content)
+	} else {
+		var err error
+		if body, err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
+
+	request, err := http.NewRequest(n.method, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range n.headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("the webhook responded with '%s'", response.Status)
+	}
+	return nil
+}
+
+// SmtpNotifier sends the event by email.
+type SmtpNotifier struct {
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+	subject  *template.Template
+	body     *template.Template
+}
+
+func (n *SmtpNotifier) Notify(event *Event) error {
+	subject, err := render(n.subject, event)
+	if err != nil {
+		return err
+	}
+	body, err := render(n.body, event)
+	if err != nil {
+		return err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", n.from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&message, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if n.username != "" {
+		host, _, err := net.SplitHostPort(n.host)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", n.username, n.password, host)
+	}
+	return smtp.SendMail(n.host, auth, n.from, n.to, message.Bytes())
+}
+
+// ExecNotifier runs a local command with the event in its environment.
+type ExecNotifier struct {
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+func (n *ExecNotifier) Notify(event *Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, n.command, n.args...)
+	cmd.Env = append(os.Environ(),
+		"DDNS_EVENT="+event.Event,
+		"DDNS_KEY="+event.Key,
+		"DDNS_DOMAIN="+event.DomainName,
+		"DDNS_RR="+event.RR,
+		"DDNS_TYPE="+event.Type,
+		"DDNS_OLD_IP="+event.OldIP,
+		"DDNS_NEW_IP="+event.NewIP,
+		"DDNS_ERROR="+event.Error,
+		fmt.Sprintf("DDNS_FAILURES=%d", event.Failures),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s, output: %s", err.Error(), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func NewNotifier(conf *NotifierConfig) (Notifier, error) {
+	switch conf.Type {
+	case "webhook":
+		if conf.URL == "" {
+			return nil, errors.New("the URL of the webhook notifier must be specified")
+		}
+		n := &WebhookNotifier{
+			url:     conf.URL,
+			method:  conf.Method,
+			headers: conf.Headers,
+			client:  &http.Client{Timeout: 10 * time.Second},
+		}
+		if n.method == "" {
+			n.method = http.MethodPost
+		}
+		if conf.Body != "" {
+			body, err := template.New("body").Funcs(templateFuncs).Parse(conf.Body)
+			if err != nil {
+				return nil, fmt.Errorf("the body template of the webhook notifier is illegal: %s", err.Error())
+			}
+			n.body = body
+		}
+		return n, nil
+	case "smtp":
+		if conf.Host == "" || conf.From == "" || len(conf.To) == 0 {
+			return nil, errors.New("the Host, From and To of the smtp notifier must be specified")
+		}
+		subject := utility.DefaultIfEmpty(&conf.Subject, tea.String("[alidns] {{.Event}}: {{.RR}}.{{.DomainName}} {{.Type}}"))
+		body := utility.DefaultIfEmpty(&conf.Body, tea.String(
+			"Event:  {{.Event}}\nRecord: {{.RR}}.{{.DomainName}} {{.Type}}\nOld IP: {{.OldIP}}\nNew IP: {{.NewIP}}\n"+
+				"{{if .Error}}Error:  {{.Error}} ({{.Failures}} failures)\n{{end}}Time:   {{.Time}}\n"))
+		n := &SmtpNotifier{
+			host:     conf.Host,
+			username: conf.Username,
+			password: conf.Password,
+			from:     conf.From,
+			to:       conf.To,
+		}
+		var err error
+		if n.subject, err = template.New("subject").Funcs(templateFuncs).Parse(*subject); err != nil {
+			return nil, fmt.Errorf("the subject template of the smtp notifier is illegal: %s", err.Error())
+		}
+		if n.body, err = template.New("body").Funcs(templateFuncs).Parse(*body); err != nil {
+			return nil, fmt.Errorf("the body template of the smtp notifier is illegal: %s", err.Error())
+		}
+		return n, nil
+	case "exec":
+		if conf.Command == "" {
+			return nil, errors.New("the Command of the exec notifier must be specified")
+		}
+		return &ExecNotifier{command: conf.Command, args: conf.Args, timeout: 30 * time.Second}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type '%s'", conf.Type)
+	}
+}
+
+// limitedNotifier delivers the subscribed events to a notifier, dropping the
+// events that arrive within RateLimit seconds of the previous delivery.
+type limitedNotifier struct {
+	Notifier
+	name      string
+	events    []string
+	rateLimit time.Duration
+	mutex     sync.Mutex
+	last      time.Time
+}
+
+func (n *limitedNotifier) accept(event *Event) bool {
+	if len(n.events) > 0 {
+		subscribed := false
+		for _, e := range n.events {
+			if e == event.Event {
+				subscribed = true
+				break
+			}
+		}
+		if !subscribed {
+			return false
+		}
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.rateLimit > 0 && !n.last.IsZero() && event.Time.Sub(n.last) < n.rateLimit*time.Second {
+		utility.Debugf("The %s notifier is rate limited, the '%s' event of '%s' is dropped.", n.name, event.Event, event.Key)
+		return false
+	}
+	n.last = event.Time
+	return true
+}
+
+// Notifiers dispatches events to all configured notifiers in the background.
+type Notifiers struct {
+	notifiers []*limitedNotifier
+	wg        sync.WaitGroup
+	// DryRun logs the events instead of sending them.
+	DryRun bool
+}
+
+func (ns *Notifiers) Notify(event *Event) {
+	if ns == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, n := range ns.notifiers {
+		if !n.accept(event) {
+			continue
+		}
+		if ns.DryRun {
+			utility.Infof("[DRY-RUN] The '%s' event of '%s' would be sent by the %s notifier.", event.Event, event.Key, n.name)
+			continue
+		}
+		ns.wg.Add(1)
+		go func(n *limitedNotifier) {
+			defer ns.wg.Done()
+			if err := n.Notify(event); err != nil {
+				utility.Errorf("Failed to send the '%s' event of '%s' by the %s notifier: %s", event.Event, event.Key, n.name, err.Error())
+			}
+		}(n)
+	}
+}
+
+// Wait waits for the notifications in flight to be delivered.
+func (ns *Notifiers) Wait() {
+	if ns != nil {
+		ns.wg.Wait()
+	}
+}
+
+// WaitContext waits for the notifications being sent until the context is
+// done, it returns false if some were still being sent.
+func (ns *Notifiers) WaitContext(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		ns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func NewNotifiers(confs []*NotifierConfig) (*Notifiers, error) {
+	if len(confs) == 0 {
+		return nil, nil
+	}
+	ns := &Notifiers{}
+	for i, conf := range confs {
+		n, err := NewNotifier(conf)
+		if err != nil {
+			return nil, fmt.Errorf("notifier #%d: %s", i+1, err.Error())
+		}
+		ns.notifiers = append(ns.notifiers, &limitedNotifier{
+			Notifier:  n,
+			name:      conf.Type,
+			events:    conf.Events,
+			rateLimit: conf.RateLimit,
+		})
+	}
+	return ns, nil
+}
@@ -0,0 +1,259 @@
+package ddns
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEvent() *Event {
+	return &Event{
+		Event:      EventChange,
+		Key:        "www.example.com/A",
+		DomainName: "example.com",
+		RR:         "www",
+		Type:       "A",
+		OldIP:      "203.0.113.10",
+		NewIP:      "203.0.113.11",
+		Time:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var method, header string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, header = r.Method, r.Header.Get("X-Token")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	n, err := NewNotifier(&NotifierConfig{Type: "webhook", URL: server.URL, Headers: map[string]string{"X-Token": "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || header != "secret" {
+		t.Errorf("got %s with X-Token '%s', want POST with 'secret'", method, header)
+	}
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("the body is not the JSON event: %s", err.Error())
+	}
+	if event.NewIP != "203.0.113.11" || event.Key != "www.example.com/A" {
+		t.Errorf("got event %+v", event)
+	}
+}
+
+func TestWebhookNotifierTemplate(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	n, err := NewNotifier(&NotifierConfig{Type: "webhook", URL: server.URL, Method: http.MethodPut, Body: `{"text": {{json .NewIP}}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"text": "203.0.113.11"}` {
+		t.Errorf("got body %s", body)
+	}
+}
+
+func TestWebhookNotifierStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	n, err := NewNotifier(&NotifierConfig{Type: "webhook", URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testEvent()); err == nil {
+		t.Error("a 500 response is not reported as an error")
+	}
+}
+
+// smtpServer accepts a single message without authentication and sends it
+// to the channel, with the recipients.
+func smtpServer(t *testing.T) (string, chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP")
+
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM"):
+				reply("250 OK")
+			case strings.HasPrefix(command, "RCPT TO"):
+				lines = append(lines, strings.TrimSpace(line))
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					line = strings.TrimRight(line, "\r\n")
+					if line == "." {
+						break
+					}
+					lines = append(lines, line)
+				}
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 Bye")
+				messages <- lines
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), messages
+}
+
+func TestSmtpNotifier(t *testing.T) {
+	host, messages := smtpServer(t)
+
+	n, err := NewNotifier(&NotifierConfig{Type: "smtp", Host: host, From: "ddns@example.com", To: []string{"admin@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case lines := <-messages:
+		message := strings.Join(lines, "\n")
+		for _, want := range []string{
+			"RCPT TO:<admin@example.com>",
+			"Subject: [alidns] change: www.example.com A",
+			"New IP: 203.0.113.11",
+		} {
+			if !strings.Contains(message, want) {
+				t.Errorf("the message does not contain '%s':\n%s", want, message)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message was received")
+	}
+}
+
+func TestExecNotifier(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the script needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	output := filepath.Join(dir, "event")
+	script := filepath.Join(dir, "notify.sh")
+	content := "#!/bin/sh\necho \"$1 $DDNS_EVENT $DDNS_RR.$DDNS_DOMAIN $DDNS_NEW_IP $DDNS_FAILURES\" > " + output + "\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewNotifier(&NotifierConfig{Type: "exec", Command: script, Args: []string{"arg"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(data)), "arg change www.example.com 203.0.113.11 0"; got != want {
+		t.Errorf("got '%s', want '%s'", got, want)
+	}
+}
+
+func TestExecNotifierFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the script needs a POSIX shell")
+	}
+	n, err := NewNotifier(&NotifierConfig{Type: "exec", Command: "/bin/sh", Args: []string{"-c", "echo boom; exit 3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Notify(testEvent())
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("got error %v, want the output of the failed command", err)
+	}
+}
+
+func TestLimitedNotifier(t *testing.T) {
+	n := &limitedNotifier{name: "test", events: []string{EventChange, EventFailure}, rateLimit: 60}
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	event := func(name string, after time.Duration) *Event {
+		return &Event{Event: name, Key: "www.example.com/A", Time: start.Add(after)}
+	}
+
+	for _, c := range []struct {
+		event *Event
+		want  bool
+	}{
+		{event(EventChange, 0), true},
+		// not subscribed, it does not count as a delivery either
+		{event(EventSuccess, 10*time.Second), false},
+		{event(EventFailure, 30*time.Second), false},
+		{event(EventChange, 59*time.Second), false},
+		{event(EventChange, 60*time.Second), true},
+		{event(EventFailure, 61*time.Second), false},
+		{event(EventFailure, 2*time.Minute), true},
+	} {
+		if got := n.accept(c.event); got != c.want {
+			t.Errorf("%s event at +%s: accepted %v, want %v", c.event.Event, c.event.Time.Sub(start), got, c.want)
+		}
+	}
+}
+
+func TestLimitedNotifierUnlimited(t *testing.T) {
+	n := &limitedNotifier{name: "test"}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !n.accept(&Event{Event: EventSuccess, Time: now}) {
+			t.Errorf("event #%d was dropped without a rate limit", i+1)
+		}
+	}
+}
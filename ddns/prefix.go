@@ -0,0 +1,111 @@
+package ddns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// PrefixHost is a LAN host whose AAAA record follows the delegated IPv6 prefix.
+// Its address is the prefix, the Subnet-th /64 sub-prefix of it, and an
+// interface identifier that is either the static Suffix, such as "::10", or
+// the EUI-64 identifier derived from the MAC address.
+type PrefixHost struct {
+	RR     *string `json:"RR"`
+	Suffix *string `json:"Suffix"`
+	MAC    *string `json:"MAC"`
+	Subnet uint64  `json:"Subnet"`
+	TTL    *int64  `json:"TTL"`
+}
+
+// PrefixConfig derives the AAAA records of many hosts from one delegated
+// prefix of Length bits. The prefix is taken from the global address of
+// Interface, or from the detected IPv6 address if no interface is specified.
+type PrefixConfig struct {
+	Interface  *string       `json:"Interface"`
+	Length     int           `json:"Length"`
+	DomainName *string       `json:"DomainName"`
+	Hosts      []*PrefixHost `json:"Hosts"`
+}
+
+// eui64 returns the modified EUI-64 interface identifier of a MAC address.
+func eui64(mac net.HardwareAddr) ([]byte, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("'%s' is not a 48-bit MAC address", mac.String())
+	}
+	return []byte{mac[0] ^ 0x02, mac[1], mac[2], 0xff, 0xfe, mac[3], mac[4], mac[5]}, nil
+}
+
+// suffix returns the bits the host adds to the delegated prefix.
+func (host *PrefixHost) suffix(length int) (net.IP, error) {
+	ip := make(net.IP, net.IPv6len)
+
+	switch {
+	case host.Suffix != nil && *host.Suffix != "" && host.MAC != nil && *host.MAC != "":
+		return nil, errors.New("only one of Suffix and MAC can be specified")
+	case host.Suffix != nil && *host.Suffix != "":
+		suffix := net.ParseIP(*host.Suffix)
+		if suffix == nil || suffix.To4() != nil {
+			return nil, fmt.Errorf("the suffix '%s' is not an IPv6 address", *host.Suffix)
+		}
+		copy(ip, suffix)
+	case host.MAC != nil && *host.MAC != "":
+		mac, err := net.ParseMAC(*host.MAC)
+		if err != nil {
+			return nil, err
+		}
+		id, err := eui64(mac)
+		if err != nil {
+			return nil, err
+		}
+		copy(ip[8:], id)
+	default:
+		return nil, errors.New("either Suffix or MAC must be specified")
+	}
+
+	if !ip.Mask(net.CIDRMask(length, 128)).IsUnspecified() {
+		return nil, fmt.Errorf("the suffix overlaps the /%d prefix", length)
+	}
+
+	if host.Subnet > 0 {
+		if host.Subnet >= 1<<(64-length) {
+			return nil, fmt.Errorf("the subnet %d does not fit in the /%d prefix", host.Subnet, length)
+		}
+		upper := binary.BigEndian.Uint64(ip[:8])
+		if upper&host.Subnet != 0 {
+			return nil, fmt.Errorf("the subnet %d overlaps the suffix", host.Subnet)
+		}
+		binary.BigEndian.PutUint64(ip[:8], upper|host.Subnet)
+	}
+	return ip, nil
+}
+
+// expand returns a DDNS entry for every host, following the delegated prefix.
+func (conf *PrefixConfig) expand() ([]*DDNS, error) {
+	if conf.Length <= 0 || conf.Length > 64 {
+		return nil, fmt.Errorf("the prefix length %d is out of range, it must be within 1 to 64", conf.Length)
+	}
+
+	var list []*DDNS
+	for i, host := range conf.Hosts {
+		if host.RR == nil || *host.RR == "" {
+			return nil, fmt.Errorf("prefix host #%d: RR cannot be nil or empty", i+1)
+		}
+		suffix, err := host.suffix(conf.Length)
+		if err != nil {
+			return nil, fmt.Errorf("prefix host '%s': %s", *host.RR, err.Error())
+		}
+		list = append(list, &DDNS{
+			DomainName: conf.DomainName,
+			RR:         host.RR,
+			Type:       tea.String("AAAA"),
+			TTL:        host.TTL,
+			Network:    tea.String(fmt.Sprintf("%s/%d", suffix.String(), conf.Length)),
+			delegated:  true,
+		})
+	}
+	return list, nil
+}
@@ -0,0 +1,186 @@
+package ddns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kdiot/alidns-console/utility"
+)
+
+const unixPrefix = "unix:"
+
+// Listen opens the listener of the status API, the address is either a TCP
+// address such as '127.0.0.1:8053' or a Unix socket such as 'unix:/run/aliddns.sock'.
+func Listen(address string) (net.Listener, error) {
+	if strings.HasPrefix(address, unixPrefix) {
+		path := strings.TrimPrefix(address, unixPrefix)
+		if _, err := os.Stat(path); err == nil {
+			// remove the socket left behind by a previous run
+			os.Remove(path)
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", address)
+}
+
+// NewClient returns an HTTP client that talks to the status API at the given address,
+// and the base URL of the API.
+func NewClient(address string) (*http.Client, string) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if strings.HasPrefix(address, unixPrefix) {
+		path := strings.TrimPrefix(address, unixPrefix)
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		}
+		return client, "http://unix"
+	}
+	return client, "http://" + address
+}
+
+type server struct {
+	daemon  *Daemon
+	servers []*http.Server
+}
+
+func (srv *server) writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func (srv *server) writeError(w http.ResponseWriter, code int, err error) {
+	srv.writeJSON(w, code, map[string]string{"Error": err.Error()})
+}
+
+func (srv *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		srv.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+
+	var status *DaemonStatus
+	err := srv.daemon.call(r.Context(), func() {
+		status = srv.daemon.Status()
+	})
+	if err != nil {
+		srv.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	srv.writeJSON(w, http.StatusOK, status)
+}
+
+func (srv *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		srv.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+
+	var status *DaemonStatus
+	err := srv.daemon.call(r.Context(), func() {
+		status = srv.daemon.Status()
+	})
+	if err != nil {
+		srv.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	status.WriteMetrics(w)
+}
+
+func (srv *server) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		srv.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+
+	if err := srv.daemon.call(r.Context(), srv.daemon.doCheck); err != nil {
+		srv.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	srv.writeJSON(w, http.StatusOK, map[string]string{"Result": "checked"})
+}
+
+func (srv *server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		srv.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		srv.writeError(w, http.StatusBadRequest, errors.New("the record key must be specified"))
+		return
+	}
+
+	code, result := http.StatusNotFound, fmt.Errorf("no dynamic domain name record '%s'", key)
+	err := srv.daemon.call(r.Context(), func() {
+		for _, service := range srv.daemon.services {
+			if service.key != key {
+				continue
+			}
+			ips := srv.daemon.addresses(service)
+			if len(ips) == 0 {
+				code, result = http.StatusConflict, errors.New("the IP address has not been detected yet")
+				return
+			}
+			service.PostForceUpdate(ips...)
+			code, result = http.StatusAccepted, nil
+			return
+		}
+	})
+	if err != nil {
+		srv.writeError(w, http.StatusServiceUnavailable, err)
+	} else if result != nil {
+		srv.writeError(w, code, result)
+	} else {
+		srv.writeJSON(w, code, map[string]string{"Result": "updating"})
+	}
+}
+
+// serve serves the status API and the metrics on the listener.
+func (srv *server) serve(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/status", srv.handleStatus)
+	mux.HandleFunc("/v1/check", srv.handleCheck)
+	mux.HandleFunc("/v1/records/update", srv.handleUpdate)
+	mux.HandleFunc("/metrics", srv.handleMetrics)
+	srv.start(listener, mux)
+}
+
+// serveMetrics serves only the metrics on the listener, so that it can be
+// exposed to a Prometheus server without exposing the control actions.
+func (srv *server) serveMetrics(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", srv.handleMetrics)
+	srv.start(listener, mux)
+}
+
+func (srv *server) start(listener net.Listener, handler http.Handler) {
+	server := &http.Server{Handler: handler}
+	srv.servers = append(srv.servers, server)
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			utility.Errorf("The HTTP server on '%s' stopped unexpectedly: %s", listener.Addr().String(), err.Error())
+		}
+	}()
+}
+
+func (srv *server) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, server := range srv.servers {
+		server.Shutdown(ctx)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,59 +18,248 @@ type Network struct {
 	Mask net.IPMask
 }
 
+type SyncResult int
+
+const (
+	SYNC_UNCHANGED SyncResult = iota
+	SYNC_UPDATED
+	SYNC_FAILED
+	SYNC_TIMEOUT
+)
+
+func (r SyncResult) String() string {
+	switch r {
+	case SYNC_UNCHANGED:
+		return "unchanged"
+	case SYNC_UPDATED:
+		return "updated"
+	case SYNC_FAILED:
+		return "failed"
+	case SYNC_TIMEOUT:
+		return "timeout"
+	default:
+		return ""
+	}
+}
+
+// ServiceConfig carries a reloaded DDNS entry to a running UpdateService.
+type ServiceConfig struct {
+	DDNS             *DDNS
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
+	FailureThreshold int
+	Notifiers        *Notifiers
+	// Redetect tells that the sources or the failover of the record changed,
+	// the published addresses are stale until the daemon detects them again.
+	Redetect bool
+}
+
+// defaultOwner is the Remark tag of the records owned by multi-value records.
+const defaultOwner = "alidns-ddns"
+
 type UpdateService struct {
-	api           *utility.AlidnsApi
-	record        *utility.DomainRecord
-	network       *Network
-	retryTimer    *time.Timer
-	retryInterval time.Duration
-	IpAddrChan    chan *net.IP
+	api         *utility.AlidnsApi
+	dryRun      bool
+	delegated   bool
+	multi       bool
+	sources     []ExternalIP
+	sourceNames []string
+	failover    *monitor
+	owner       string
+	discovered  bool
+	log         *utility.Logger
+	ddns        *DDNS
+	// record is only touched by the service goroutine, the other goroutines
+	// read the fields of the key below, which never change
+	record     *utility.DomainRecord
+	domainName string
+	rr         string
+	recordType string
+	fqdn       string
+	ips        []net.IP
+	network    *Network
+	retryTimer *time.Timer
+	retryGen   uint64
+	retryIPs   []net.IP
+	retryForce bool
+	backoff    Backoff
+	failures   int
+	threshold  int
+	// alerted tells that the failure of the current streak was notified
+	alerted     bool
+	notifiers   *Notifiers
+	notified    string
+	state       *State
+	key         string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	statusMutex sync.Mutex
+	status      RecordStatus
+	mailbox     *mailbox
 }
 
 func (s *UpdateService) Type() *string {
-	return s.record.Type
+	return tea.String(s.recordType)
 }
 
 func (s *UpdateService) Init(d *DDNS) error {
 
-	var err error
-	if s.api == nil {
-		if s.api, err = utility.NewAlidnsApi(*d.DomainName, *d.AccessKeyId, *d.AccessKeySecret); err != nil {
-			return err
-		}
+	s.mailbox = newMailbox()
+	s.key = d.Key()
+	s.domainName = tea.StringValue(d.DomainName)
+	s.rr = tea.StringValue(d.RR)
+	s.recordType = tea.StringValue(d.Type)
+	if s.rr == "@" {
+		s.fqdn = s.domainName
+	} else {
+		s.fqdn = s.rr + "." + s.domainName
 	}
 
-	s.IpAddrChan = make(chan *net.IP)
-
 	s.record = &utility.DomainRecord{
 		DomainName: d.DomainName,
 		RR:         d.RR,
 		Type:       d.Type,
-		TTL:        d.TTL,
+		Line:       tea.String(utility.LineValue(d.Line)),
+	}
+
+	s.log = utility.WithFields(utility.Fields{
+		"RR":     tea.StringValue(d.RR),
+		"DOMAIN": tea.StringValue(d.DomainName),
+		"TYPE":   tea.StringValue(d.Type),
+		"LINE":   utility.LineValue(d.Line),
+	})
+
+	s.status = RecordStatus{
+		Key:        s.key,
+		DomainName: tea.StringValue(d.DomainName),
+		RR:         tea.StringValue(d.RR),
+		Type:       tea.StringValue(d.Type),
+		Line:       utility.LineValue(d.Line),
 	}
 
+	return s.configure(d)
+}
+
+// configure applies the settings of the DDNS entry that may change while the
+// service is running.
+func (s *UpdateService) configure(d *DDNS) error {
+
+	var network *Network
 	if d.Network != nil && *d.Network != "" {
 		if ip, ipnet, err := net.ParseCIDR(*d.Network); err != nil {
 			return fmt.Errorf(`the network address configuration is illegal!["Network": "%s"]`, *d.Network)
 		} else {
-			s.network = &Network{IP: ip, Mask: ipnet.Mask}
+			network = &Network{IP: ip, Mask: ipnet.Mask}
 		}
 	}
 
+	api := s.api
+	if api == nil || (s.ddns != nil &&
+		(tea.StringValue(s.ddns.AccessKeyId) != tea.StringValue(d.AccessKeyId) ||
+			tea.StringValue(s.ddns.AccessKeySecret) != tea.StringValue(d.AccessKeySecret))) {
+		var err error
+		if api, err = utility.NewAlidnsApi(*d.DomainName, *d.AccessKeyId, *d.AccessKeySecret); err != nil {
+			return err
+		}
+		api.DryRun = s.dryRun
+	}
+
+	s.api = api
+	s.network = network
+	// a multi-value record holds the addresses of all its sources
+	s.multi = len(d.Sources) > 0
+	s.owner = tea.StringValue(utility.DefaultIfEmpty(d.Remark, tea.String(defaultOwner)))
+	s.record.TTL = d.TTL
+	s.ddns = d
+
 	return nil
 }
 
-func (s *UpdateService) Update(ip *net.IP) {
+// Reconfigure applies a reloaded DDNS entry to the running service. A pending
+// retry is kept and will publish the record with the new settings, unless the
+// addresses are detected again.
+func (s *UpdateService) Reconfigure(conf *ServiceConfig) {
+	s.backoff.Base = conf.RetryInterval
+	s.backoff.Max = conf.MaxRetryInterval
+	s.threshold = conf.FailureThreshold
+	s.notifiers = conf.Notifiers
+	if s.ddns.Equal(conf.DDNS) {
+		return
+	}
 
-	if s.retryTimer != nil {
-		s.retryTimer.Stop()
-		s.retryTimer = nil
+	if err := s.configure(conf.DDNS); err != nil {
+		s.log.Errorf("Failed to reconfigure the dynamic domain name record '%s.%s', keep the previous configuration: %s",
+			tea.StringValue(s.record.RR),
+			tea.StringValue(s.record.DomainName),
+			utility.ErrMsg(err),
+		)
+		return
+	}
+	s.log.Infof("The configuration of the dynamic domain name record '%s.%s' has been reloaded.",
+		tea.StringValue(s.record.RR),
+		tea.StringValue(s.record.DomainName),
+	)
+
+	if conf.Redetect {
+		// the daemon posts the addresses of the new sources or failover
+		s.stopRetry()
+	} else if s.ips != nil && s.retryTimer == nil {
+		s.update(s.ips, true)
+	}
+}
+
+// alertFailure notifies the failure once per streak of failures: at once for a
+// permanent error, once the threshold is reached for the others.
+func (s *UpdateService) alertFailure(class utility.ErrorClass, oldValue string, err error) {
+	if s.alerted || (class != utility.ERROR_FATAL && s.failures < s.threshold) {
+		return
 	}
+	s.alerted = true
+	s.notify(EventFailure, oldValue, err)
+}
 
-	if ip == nil {
+// SetNotifiers sets the notifiers of the events of the record.
+func (s *UpdateService) SetNotifiers(notifiers *Notifiers) {
+	s.notifiers = notifiers
+}
+
+func (s *UpdateService) notify(event string, oldIP string, err error) {
+	e := &Event{
+		Event:      event,
+		Key:        s.key,
+		DomainName: tea.StringValue(s.record.DomainName),
+		RR:         tea.StringValue(s.record.RR),
+		Type:       tea.StringValue(s.record.Type),
+		OldIP:      oldIP,
+		NewIP:      tea.StringValue(s.record.Value),
+		Failures:   s.failures,
+	}
+	if err != nil {
+		e.Error = utility.ErrMsg(err)
+	}
+	s.notifiers.Notify(e)
+}
+
+// SetState attaches the persisted state to the service, restoring the RecordId
+// published by a previous run of the daemon.
+func (s *UpdateService) SetState(state *State) {
+	s.state = state
+	if state == nil {
 		return
 	}
+	if rs := state.Get(s.key); rs != nil {
+		if rs.RecordId != nil {
+			s.record.RecordId = rs.RecordId
+		}
+		s.setStatus(func(status *RecordStatus) {
+			status.Value = tea.StringValue(rs.Value)
+			status.LastSuccess = &rs.Updated
+		})
+	}
+}
 
+// Value returns the record value to be published for the detected IP address.
+func (s *UpdateService) Value(ip *net.IP) string {
 	if s.network != nil {
 		prefix := ip.Mask(s.network.Mask)
 		n := len(*ip)
@@ -76,46 +267,409 @@ func (s *UpdateService) Update(ip *net.IP) {
 		for i := 0; i < n; i++ {
 			newip[i] = prefix[i] | s.network.IP[i]
 		}
-		s.record.Value = tea.String(newip.String())
+		return newip.String()
+	} else {
+		return ip.String()
+	}
+}
+
+// Status returns a snapshot of the publishing state of the record.
+func (s *UpdateService) Status() RecordStatus {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+	return s.status
+}
+
+func (s *UpdateService) setStatus(fn func(status *RecordStatus)) {
+	s.statusMutex.Lock()
+	defer s.statusMutex.Unlock()
+	fn(&s.status)
+}
+
+// values returns the sorted record values to be published for the IP addresses.
+func (s *UpdateService) values(ips []net.IP) []string {
+	seen := map[string]bool{}
+	var values []string
+	for i := range ips {
+		if value := s.Value(&ips[i]); !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+func (s *UpdateService) Update(ip *net.IP) {
+	s.update([]net.IP{*ip}, false)
+}
+
+// Sync publishes the IP addresses synchronously, and tells whether the record
+// was updated, left unchanged or failed to update.
+func (s *UpdateService) Sync(ips []net.IP) SyncResult {
+	before := s.Status()
+	s.update(ips, false)
+	after := s.Status()
+
+	if after.Failures > before.Failures {
+		return SYNC_FAILED
+	} else if after.Successes > before.Successes {
+		return SYNC_UPDATED
 	} else {
-		s.record.Value = tea.String(ip.String())
+		return SYNC_UNCHANGED
+	}
+}
+
+// ForceUpdate publishes the record even if the persisted state says it is up to date.
+func (s *UpdateService) ForceUpdate(ip *net.IP) {
+	s.update([]net.IP{*ip}, true)
+}
+
+func (s *UpdateService) update(ips []net.IP, force bool) {
+	s.stopRetry()
+	s.backoff.Reset()
+	s.publish(ips, force)
+}
+
+func (s *UpdateService) stopRetry() {
+	if s.retryTimer != nil {
+		s.retryTimer.Stop()
+		s.retryTimer = nil
+		s.setStatus(func(status *RecordStatus) { status.NextRetry = nil })
+	}
+	// invalidate a retry event that may already be in the mailbox
+	s.retryGen++
+}
+
+// publish pushes the values for the IP addresses to DNS, scheduling a retry
+// with backoff if it fails with an error that is worth retrying. A record that
+// is not multi-value only holds the first address.
+func (s *UpdateService) publish(ips []net.IP, force bool) {
+
+	if len(ips) == 0 {
+		return
+	}
+	if !s.multi {
+		ips = ips[:1]
+	}
+	s.ips = ips
+
+	values := s.values(ips)
+	s.record.Value = tea.String(strings.Join(values, ","))
+
+	if s.state != nil && !force {
+		if rs := s.state.Get(s.key); rs != nil && tea.StringValue(rs.Value) == tea.StringValue(s.record.Value) {
+			s.log.Debugf("The dynamic domain name record '%s.%s' is already %s since %s, no need to update.",
+				tea.StringValue(s.record.RR),
+				tea.StringValue(s.record.DomainName),
+				tea.StringValue(rs.Value),
+				rs.Updated.Format(time.RFC3339),
+			)
+			return
+		}
 	}
 
-	utility.Debug("UpdateService.Update: begin update...")
-	if err := s.api.AutoUpdate(s.record); err != nil {
+	oldValue := s.Status().Value
+	if value := tea.StringValue(s.record.Value); value != oldValue && value != s.notified {
+		s.notified = value
+		s.notify(EventChange, oldValue, nil)
+	}
+
+	s.log.Debug("UpdateService.Update: begin update...")
+	s.setStatus(func(status *RecordStatus) { status.Attempts++ })
+	var err error
+	if s.multi {
+		_, err = s.api.AutoUpdateSet(s.record, values, s.owner)
+	} else {
+		// AutoUpdate rewrites the record it is given, only its RecordId is kept
+		record := *s.record
+		if err = s.api.AutoUpdate(&record); err == nil {
+			s.record.RecordId = record.RecordId
+		}
+	}
+	if err != nil {
 		if e, ok := err.(*tea.SDKError); ok {
-			utility.Errorf("Update dynamic domain name record '%s.%s' failed! Error message: %s, %s",
+			s.log.Errorf("Update dynamic domain name record '%s.%s' failed! Error message: %s, %s",
 				tea.StringValue(s.record.RR),
 				tea.StringValue(s.record.DomainName),
 				tea.StringValue(e.Code),
 				tea.StringValue(e.Message),
 			)
 		} else {
-			utility.Errorf("Update dynamic domain name record '%s.%s' failed! Error message: %s",
+			s.log.Errorf("Update dynamic domain name record '%s.%s' failed! Error message: %s",
 				tea.StringValue(s.record.RR),
 				tea.StringValue(s.record.DomainName),
 				err.Error(),
 			)
 		}
-		if s.retryInterval > 0 {
-			s.retryTimer = time.AfterFunc(time.Second*s.retryInterval, func() {
-				s.retryTimer = nil
-				s.Update(ip)
+		now := time.Now()
+		class := utility.ClassifyError(err)
+		s.setStatus(func(status *RecordStatus) {
+			status.LastError = utility.ErrMsg(err)
+			status.LastFailure = &now
+			status.Failures++
+		})
+		s.failures++
+
+		if class == utility.ERROR_FATAL {
+			s.log.Errorf("The error of dynamic domain name record '%s.%s' is permanent, it will NOT be retried! "+
+				"Please check the configuration, the record will be updated again when the IP address changes.",
+				tea.StringValue(s.record.RR),
+				tea.StringValue(s.record.DomainName),
+			)
+			s.alertFailure(class, oldValue, err)
+			return
+		}
+
+		s.alertFailure(class, oldValue, err)
+		if s.backoff.Base > 0 {
+			delay := s.backoff.Next(class)
+			next := now.Add(delay)
+			s.setStatus(func(status *RecordStatus) { status.NextRetry = &next })
+			s.log.Infof("Retry updating the dynamic domain name record '%s.%s' in %s (%s error).",
+				tea.StringValue(s.record.RR),
+				tea.StringValue(s.record.DomainName),
+				delay.Round(time.Second),
+				class,
+			)
+			// the retry is delivered to the service goroutine as an event
+			s.retryGen++
+			gen := s.retryGen
+			s.retryIPs, s.retryForce = ips, force
+			s.retryTimer = time.AfterFunc(delay, func() {
+				s.mailbox.post(func(m *mailbox) { m.retry = gen })
 			})
 		}
 	} else {
-		utility.Infof("Update the dynamic domain name record '%s.%s' successfully! The new IP address is: %s",
+		now := time.Now()
+		s.setStatus(func(status *RecordStatus) {
+			status.Value = tea.StringValue(s.record.Value)
+			status.LastSuccess = &now
+			status.LastError = ""
+			status.Successes++
+		})
+		s.failures, s.alerted = 0, false
+		s.notify(EventSuccess, oldValue, nil)
+		s.log.Infof("Update the dynamic domain name record '%s.%s' successfully! The new IP address is: %s",
 			tea.StringValue(s.record.RR),
 			tea.StringValue(s.record.DomainName),
 			tea.StringValue(s.record.Value),
 		)
+		if s.state != nil {
+			err := s.state.Set(s.key, &RecordState{
+				RecordId: s.record.RecordId,
+				Value:    s.record.Value,
+				Updated:  now,
+			})
+			if err != nil {
+				s.log.Warningf("Failed to save the state of dynamic domain name record '%s.%s': %s",
+					tea.StringValue(s.record.RR),
+					tea.StringValue(s.record.DomainName),
+					err.Error(),
+				)
+			}
+		}
+	}
+}
+
+// Reconcile reads the domain name record from DNS and rewrites it if its value
+// has drifted from the one expected for the detected IP address.
+func (s *UpdateService) Reconcile(ips []net.IP) {
+	if len(ips) == 0 || ips[0].IsUnspecified() {
+		return
+	}
+	if s.multi {
+		s.reconcileSet(ips)
+		return
+	}
+
+	ip := &ips[0]
+	value := s.Value(ip)
+	current, err := s.retrieve()
+	if err != nil {
+		s.log.Errorf("Failed to read the dynamic domain name record '%s.%s' for reconciliation! Error message: %s",
+			tea.StringValue(s.record.RR),
+			tea.StringValue(s.record.DomainName),
+			utility.ErrMsg(err),
+		)
+		return
+	}
+
+	if current == nil {
+		s.log.Warningf("The dynamic domain name record '%s.%s' does not exist, it will be created with the value: %s",
+			tea.StringValue(s.record.RR),
+			tea.StringValue(s.record.DomainName),
+			value,
+		)
+		s.record.RecordId = nil
+	} else if tea.StringValue(current.Value) != value {
+		s.log.Warningf("The dynamic domain name record '%s.%s' has drifted from %s to %s, it will be rewritten.",
+			tea.StringValue(s.record.RR),
+			tea.StringValue(s.record.DomainName),
+			value,
+			tea.StringValue(current.Value),
+		)
+		s.record.RecordId = current.RecordId
+	} else {
+		s.log.Debugf("The dynamic domain name record '%s.%s' is consistent with DNS.",
+			tea.StringValue(s.record.RR),
+			tea.StringValue(s.record.DomainName),
+		)
+		return
+	}
+
+	s.update(ips, true)
+}
+
+// reconcileSet rewrites the records of a multi-value record if a value is
+// missing from DNS, or if a record owned by the service holds an extra value.
+func (s *UpdateService) reconcileSet(ips []net.IP) {
+	values := s.values(ips)
+	records, err := s.api.Query(&utility.QueryInfo{
+		RR:   s.record.RR,
+		Type: s.record.Type,
+		Line: s.record.Line,
+	})
+	if err != nil {
+		s.log.Errorf("Failed to read the dynamic domain name record '%s.%s' for reconciliation! Error message: %s",
+			tea.StringValue(s.record.RR),
+			tea.StringValue(s.record.DomainName),
+			utility.ErrMsg(err),
+		)
+		return
+	}
+
+	wanted := map[string]bool{}
+	for _, value := range values {
+		wanted[value] = true
+	}
+	held, extra := 0, false
+	for _, record := range records {
+		if tea.StringValue(record.RR) != tea.StringValue(s.record.RR) || utility.LineValue(record.Line) != *s.record.Line {
+			continue
+		}
+		if value := tea.StringValue(record.Value); wanted[value] {
+			delete(wanted, value)
+			held++
+		} else if tea.StringValue(record.Remark) == s.owner {
+			extra = true
+		}
+	}
+
+	if held == len(values) && !extra {
+		s.log.Debugf("The dynamic domain name record '%s.%s' is consistent with DNS.",
+			tea.StringValue(s.record.RR),
+			tea.StringValue(s.record.DomainName),
+		)
+		return
+	}
+	s.log.Warningf("The dynamic domain name record '%s.%s' has drifted from %s, it will be rewritten.",
+		tea.StringValue(s.record.RR),
+		tea.StringValue(s.record.DomainName),
+		strings.Join(values, ","),
+	)
+	s.update(ips, true)
+}
+
+// retrieve reads the managed domain name record, by RecordId if it is known,
+// otherwise by RR, Type and Line. It returns nil if the record does not exist.
+func (s *UpdateService) retrieve() (*utility.DomainRecord, error) {
+	if s.record.RecordId != nil {
+		if record, err := s.api.Retrieve(*s.record.RecordId); err == nil {
+			if tea.StringValue(record.RR) == tea.StringValue(s.record.RR) &&
+				tea.StringValue(record.Type) == tea.StringValue(s.record.Type) &&
+				utility.LineValue(record.Line) == *s.record.Line {
+				return record, nil
+			}
+		}
+	}
+
+	records, err := s.api.Query(&utility.QueryInfo{
+		RR:   s.record.RR,
+		Type: s.record.Type,
+		Line: s.record.Line,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		// RRKeyWord is a fuzzy match, so compare the RR and the line exactly
+		if tea.StringValue(record.RR) == tea.StringValue(s.record.RR) && utility.LineValue(record.Line) == *s.record.Line {
+			return record, nil
+		}
+	}
+	return nil, nil
+}
+
+// PostUpdate asks the service to publish the IP addresses, it never blocks.
+func (s *UpdateService) PostUpdate(ips ...net.IP) {
+	s.mailbox.post(func(m *mailbox) { m.update = ips })
+}
+
+// PostForceUpdate asks the service to publish the IP address even if the
+// persisted state says it is up to date, it never blocks.
+func (s *UpdateService) PostForceUpdate(ips ...net.IP) {
+	s.mailbox.post(func(m *mailbox) { m.update, m.force = ips, true })
+}
+
+// PostReconcile asks the service to check the record in DNS, it never blocks.
+func (s *UpdateService) PostReconcile(ips ...net.IP) {
+	s.mailbox.post(func(m *mailbox) { m.reconcile = ips })
+}
+
+// PostConfig asks the service to apply a reloaded configuration, it never blocks.
+func (s *UpdateService) PostConfig(conf *ServiceConfig) {
+	s.mailbox.post(func(m *mailbox) { m.config = conf })
+}
+
+// PostRemove asks the service to delete its record from DNS and stop, it never blocks.
+func (s *UpdateService) PostRemove() {
+	s.mailbox.post(func(m *mailbox) { m.remove = true })
+}
+
+// remove deletes the record of the service from DNS, for the discovered hosts
+// that have left the network.
+func (s *UpdateService) remove() {
+	s.stopRetry()
+
+	record, err := s.retrieve()
+	if err == nil && record != nil {
+		err = s.api.Delete(tea.StringValue(record.RecordId))
+	}
+	if err != nil {
+		s.log.Errorf("Failed to remove the stale dynamic domain name record '%s.%s'! Error message: %s",
+			tea.StringValue(s.record.RR),
+			tea.StringValue(s.record.DomainName),
+			utility.ErrMsg(err),
+		)
+		return
+	}
+	if record != nil {
+		s.log.Infof("The stale dynamic domain name record '%s.%s' has been removed.",
+			tea.StringValue(s.record.RR),
+			tea.StringValue(s.record.DomainName),
+		)
+	}
+	if s.state != nil {
+		if err := s.state.Delete(s.key); err != nil {
+			s.log.Warningf("Failed to save the state of dynamic domain name record '%s.%s': %s",
+				tea.StringValue(s.record.RR),
+				tea.StringValue(s.record.DomainName),
+				err.Error(),
+			)
+		}
 	}
 }
 
 func (s *UpdateService) Close() {
-	close(s.IpAddrChan)
+	if s.retryTimer != nil {
+		s.retryTimer.Stop()
+		s.retryTimer = nil
+	}
 }
 
+// Routine is the service goroutine, it handles the events posted to the
+// service one batch at a time, so that the record is never updated concurrently.
 func (s *UpdateService) Routine(ctx context.Context, wg *sync.WaitGroup) {
 	defer func() {
 		s.Close()
@@ -124,25 +678,56 @@ func (s *UpdateService) Routine(ctx context.Context, wg *sync.WaitGroup) {
 
 	for {
 		select {
-		case ip := <-s.IpAddrChan:
-			s.Update(ip)
-		case <-ctx.Done():
-			if s.retryTimer != nil {
-				s.retryTimer.Stop()
-				s.retryTimer = nil
+		case <-s.mailbox.wake:
+			if !s.handle(s.mailbox.take()) {
+				return
 			}
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// handle handles the events taken out of the mailbox, it returns false once
+// the service is removed. A retry is only handled if its generation is the
+// current one, an update or a removal since it was scheduled cancels it.
+func (s *UpdateService) handle(l letters) bool {
+	if l.remove {
+		s.remove()
+		if s.cancel != nil {
+			s.cancel()
+		}
+		return false
+	}
+	if l.config != nil {
+		s.Reconfigure(l.config)
+	}
+	if l.update != nil {
+		s.update(l.update, l.force)
+	} else if l.retry != 0 && l.retry == s.retryGen && s.retryTimer != nil {
+		s.retryTimer = nil
+		s.setStatus(func(status *RecordStatus) { status.NextRetry = nil })
+		s.publish(s.retryIPs, s.retryForce)
+	}
+	if l.reconcile != nil {
+		s.Reconcile(l.reconcile)
+	}
+	return true
+}
+
 func NewUpdateService(d *DDNS, conf *Config) (*UpdateService, error) {
 	s := &UpdateService{
-		retryInterval: 30,
+		backoff:   Backoff{Base: 30, Max: 3600},
+		threshold: 3,
 	}
 
 	if conf != nil {
-		s.retryInterval = conf.RetryInterval
+		s.dryRun = conf.DryRun
+		s.backoff.Base = conf.RetryInterval
+		s.backoff.Max = conf.MaxRetryInterval
+		if conf.FailureThreshold > 0 {
+			s.threshold = conf.FailureThreshold
+		}
 	}
 
 	if err := s.Init(d); err != nil {
@@ -0,0 +1,307 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/kdiot/alidns-console/utility"
+)
+
+func init() {
+	// nothing listens there, the requests of the tests fail at once
+	utility.SetEndpoint("127.0.0.1:1")
+}
+
+func testService(t *testing.T, rr string, recordType string) *UpdateService {
+	s, err := NewUpdateService(&DDNS{
+		AccessKeyId:     tea.String("id"),
+		AccessKeySecret: tea.String("secret"),
+		DomainName:      tea.String("example.com"),
+		RR:              tea.String(rr),
+		Type:            tea.String(recordType),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// publishedState makes the state say that the service already published the
+// value, so that publishing it again sends no request.
+func publishedState(s *UpdateService, value string) {
+	s.SetState(&State{Records: map[string]*RecordState{
+		s.key: {Value: tea.String(value), Updated: time.Now()},
+	}})
+}
+
+func TestMailboxCoalesce(t *testing.T) {
+	m := newMailbox()
+	first, second := &ServiceConfig{}, &ServiceConfig{}
+
+	m.post(func(m *mailbox) { m.update = []net.IP{net.ParseIP("203.0.113.1")} })
+	m.post(func(m *mailbox) { m.config = first })
+	m.post(func(m *mailbox) { m.update = []net.IP{net.ParseIP("203.0.113.2")} })
+	m.post(func(m *mailbox) { m.config = second })
+	m.post(func(m *mailbox) { m.reconcile = []net.IP{net.ParseIP("203.0.113.3")} })
+	m.post(func(m *mailbox) { m.retry = 7 })
+
+	if len(m.wake) != 1 {
+		t.Fatalf("%d wake-ups are pending, want 1", len(m.wake))
+	}
+	<-m.wake
+
+	l := m.take()
+	if len(l.update) != 1 || !l.update[0].Equal(net.ParseIP("203.0.113.2")) {
+		t.Errorf("got update %v, want the latest address", l.update)
+	}
+	if l.config != second {
+		t.Error("the config is not the latest one")
+	}
+	if len(l.reconcile) != 1 || l.retry != 7 || l.remove {
+		t.Errorf("got letters %+v", l)
+	}
+
+	if l := m.take(); l.update != nil || l.config != nil || l.reconcile != nil || l.retry != 0 {
+		t.Errorf("the mailbox is not empty once taken: %+v", l)
+	}
+}
+
+func TestMailboxForce(t *testing.T) {
+	s := testService(t, "www", "A")
+	s.PostForceUpdate(net.ParseIP("203.0.113.1"))
+	s.PostUpdate(net.ParseIP("203.0.113.2"))
+
+	// the forced update is not lost when a plain one coalesces with it
+	l := s.mailbox.take()
+	if !l.force || !l.update[0].Equal(net.ParseIP("203.0.113.2")) {
+		t.Errorf("got update %v, force %v", l.update, l.force)
+	}
+}
+
+func TestPostNeverBlocks(t *testing.T) {
+	// the service goroutine is not running, as if it were stuck in a request
+	s := testService(t, "www", "A")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			ip := net.IPv4(203, 0, 113, byte(i))
+			s.PostUpdate(ip)
+			s.PostReconcile(ip)
+			s.PostConfig(&ServiceConfig{})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("posting to a busy service blocks")
+	}
+
+	if l := s.mailbox.take(); !l.update[0].Equal(net.IPv4(203, 0, 113, 231)) {
+		t.Errorf("got update %v, want the last address", l.update)
+	}
+}
+
+func TestRetryGeneration(t *testing.T) {
+	s := testService(t, "www", "A")
+	publishedState(s, "203.0.113.10")
+	ips := []net.IP{net.ParseIP("203.0.113.10")}
+
+	pending := func(gen uint64) {
+		next := time.Now().Add(time.Hour)
+		s.retryGen = gen
+		s.retryTimer = time.NewTimer(time.Hour)
+		s.retryIPs = ips
+		s.ips = nil
+		s.setStatus(func(status *RecordStatus) { status.NextRetry = &next })
+	}
+
+	// a retry of an older generation is ignored
+	pending(2)
+	s.handle(letters{retry: 1})
+	if s.retryTimer == nil || s.ips != nil {
+		t.Fatal("a stale retry was handled")
+	}
+
+	// the retry of the current generation publishes the addresses again
+	s.handle(letters{retry: 2})
+	if s.retryTimer != nil || s.Status().NextRetry != nil {
+		t.Error("the retry is still pending once handled")
+	}
+	if len(s.ips) != 1 || !s.ips[0].Equal(ips[0]) {
+		t.Errorf("the retry published %v, want %v", s.ips, ips)
+	}
+
+	// an update cancels the pending retry, even if it is already posted
+	pending(3)
+	s.handle(letters{update: ips})
+	if s.retryTimer != nil || s.retryGen == 3 {
+		t.Fatal("the update did not cancel the pending retry")
+	}
+	s.ips = nil
+	s.handle(letters{retry: 3})
+	if s.ips != nil {
+		t.Error("the cancelled retry was handled")
+	}
+}
+
+func TestRetryScheduled(t *testing.T) {
+	s := testService(t, "www", "A")
+	// the shortest backoff, the retries come within a second
+	s.backoff.Base, s.backoff.Max = 1, 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	s.ctx, s.cancel = ctx, cancel
+	wg.Add(1)
+	go s.Routine(ctx, &wg)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	s.PostUpdate(net.ParseIP("203.0.113.10"))
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status := s.Status()
+		// the first attempt failed, and the retry posted by the timer failed too
+		if status.Attempts >= 2 && status.Failures >= 2 {
+			if status.Successes != 0 || status.LastError == "" {
+				t.Errorf("got status %+v", status)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the failed update was not retried: %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestKeyFieldsRace reads the key of the record from the main loop while the
+// service goroutine publishes it, go test -race reports a concurrent access.
+func TestKeyFieldsRace(t *testing.T) {
+	s := testService(t, "@", "AAAA")
+	publishedState(s, "2001:db8::1")
+	daemon := &Daemon{
+		services:  []*UpdateService{s},
+		ipv4:      NewExternalIPv4(nil),
+		ipv6:      NewExternalIPv6(nil),
+		submitted: map[string]net.IP{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	s.ctx, s.cancel = ctx, cancel
+	wg.Add(1)
+	go s.Routine(ctx, &wg)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	user := &DynDNSUser{Username: "user", Hostnames: []string{"*"}}
+	for i := 0; i < 200; i++ {
+		s.PostUpdate(net.ParseIP("2001:db8::1"))
+		if daemon.detector(s) != daemon.ipv6 {
+			t.Fatal("the AAAA record does not follow the IPv6 address")
+		}
+		if s.hostname() != "example.com" {
+			t.Fatalf("got host name '%s'", s.hostname())
+		}
+		daemon.submit(user, "example.com", []net.IP{net.ParseIP("2001:db8::1")})
+	}
+}
+
+func TestReconfigureRedetect(t *testing.T) {
+	s := testService(t, "www", "A")
+	s.ips = []net.IP{net.ParseIP("203.0.113.10")}
+
+	entry := func(ttl int64) *DDNS {
+		return &DDNS{
+			AccessKeyId:     tea.String("id"),
+			AccessKeySecret: tea.String("secret"),
+			DomainName:      tea.String("example.com"),
+			RR:              tea.String("www"),
+			Type:            tea.String("A"),
+			TTL:             tea.Int64(ttl),
+		}
+	}
+
+	// the addresses are published again with the new settings
+	s.handle(letters{config: &ServiceConfig{DDNS: entry(300)}})
+	if status := s.Status(); status.Attempts != 1 {
+		t.Fatalf("the record was not published with the new TTL: %+v", status)
+	}
+
+	// the addresses are stale, the daemon posts the new ones
+	s.retryTimer = time.NewTimer(time.Hour)
+	s.handle(letters{config: &ServiceConfig{DDNS: entry(600), Redetect: true}})
+	if status := s.Status(); status.Attempts != 1 {
+		t.Errorf("the stale addresses were published again: %+v", status)
+	}
+	if s.retryTimer != nil {
+		t.Error("the retry of the stale addresses is still pending")
+	}
+}
+
+// eventRecorder is a notifier keeping the events it is sent.
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (r *eventRecorder) Notify(event *Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event.Event)
+	return nil
+}
+
+func TestAlertFailureOncePerStreak(t *testing.T) {
+	recorder := &eventRecorder{}
+	notifiers := &Notifiers{notifiers: []*limitedNotifier{{Notifier: recorder, name: "test"}}}
+	s := testService(t, "www", "A")
+	s.SetNotifiers(notifiers)
+
+	failures := func(class utility.ErrorClass, n int) int {
+		for i := 0; i < n; i++ {
+			s.failures++
+			s.alertFailure(class, "", errors.New("failed"))
+		}
+		notifiers.Wait()
+		recorder.mutex.Lock()
+		defer recorder.mutex.Unlock()
+		count := len(recorder.events)
+		recorder.events = nil
+		return count
+	}
+
+	// a permanent error is notified at once, and only once
+	if n := failures(utility.ERROR_FATAL, 5); n != 1 {
+		t.Errorf("a permanent error was notified %d times", n)
+	}
+
+	// the other errors are notified once the threshold is reached
+	s.failures, s.alerted = 0, false
+	if n := failures(utility.ERROR_RETRYABLE, s.threshold-1); n != 0 {
+		t.Errorf("%d notifications below the threshold", n)
+	}
+	if n := failures(utility.ERROR_RETRYABLE, 5); n != 1 {
+		t.Errorf("a streak of errors was notified %d times", n)
+	}
+
+	// a permanent error following retryable ones is in the same streak
+	s.failures, s.alerted = 0, false
+	failures(utility.ERROR_RETRYABLE, 1)
+	if n := failures(utility.ERROR_FATAL, 2); n != 1 {
+		t.Errorf("the permanent error ending a streak was notified %d times", n)
+	}
+}
@@ -0,0 +1,121 @@
+package ddns
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RecordState is the last value successfully published for a DDNS entry.
+type RecordState struct {
+	RecordId *string   `json:"RecordId"`
+	Value    *string   `json:"Value"`
+	Updated  time.Time `json:"Updated"`
+}
+
+// State persists the published records between daemon restarts, so that a
+// restart does not push every record again.
+type State struct {
+	mutex    sync.Mutex
+	fileName string
+	// readOnly keeps the changes in memory only, for dry runs.
+	readOnly bool
+	Records  map[string]*RecordState `json:"Records"`
+}
+
+func (state *State) Load(fileName string) error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.fileName = fileName
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return json.Unmarshal(data, state)
+}
+
+// Get returns a copy of the saved state of the given key, or nil if there is none.
+func (state *State) Get(key string) *RecordState {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if rs, ok := state.Records[key]; ok {
+		result := *rs
+		return &result
+	}
+	return nil
+}
+
+// Set replaces the state of the given key and writes the state file.
+func (state *State) Set(key string, rs *RecordState) error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if state.Records == nil {
+		state.Records = map[string]*RecordState{}
+	}
+	state.Records[key] = rs
+	return state.save()
+}
+
+// Delete forgets the state of the given key and writes the state file.
+func (state *State) Delete(key string) error {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if _, ok := state.Records[key]; !ok {
+		return nil
+	}
+	delete(state.Records, key)
+	return state.save()
+}
+
+func (state *State) save() error {
+	if state.fileName == "" || state.readOnly {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file in the same directory and rename it over the
+	// original, so that a crash never leaves a truncated state file behind.
+	dir := filepath.Dir(state.fileName)
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, filepath.Base(state.fileName)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := file.Name()
+	if _, err = file.Write(data); err == nil {
+		err = file.Sync()
+	}
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return os.Rename(tmpName, state.fileName)
+}
+
+func LoadState(fileName string) (*State, error) {
+	state := &State{Records: map[string]*RecordState{}}
+	if err := state.Load(fileName); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
@@ -0,0 +1,39 @@
+package ddns
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+// TestStateFile checks that a state file cannot turn off the persistence, and
+// that the saved file only holds the records.
+func TestStateFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(fileName, []byte(`{"ReadOnly": true, "Records": {}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &State{}
+	if err := state.Load(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Set("www.example.com/A", &RecordState{Value: tea.String("203.0.113.1"), Updated: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "203.0.113.1") {
+		t.Errorf("the record was not saved: %s", data)
+	}
+	if strings.Contains(string(data), "ReadOnly") {
+		t.Errorf("the state file holds more than the records: %s", data)
+	}
+}
@@ -0,0 +1,110 @@
+package ddns
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// SourceStatus is the result of the last query of an IP address provider.
+type SourceStatus struct {
+	Source     string    `json:"Source"`
+	IP         string    `json:"IP,omitempty"`
+	Error      string    `json:"Error,omitempty"`
+	Checked    time.Time `json:"Checked"`
+	Latency    float64   `json:"Latency"`
+	LatencySum float64   `json:"LatencySum"`
+	Requests   int64     `json:"Requests"`
+	Failures   int64     `json:"Failures"`
+}
+
+// AddressStatus is the detected external IP address of one family.
+type AddressStatus struct {
+	Type    string         `json:"Type"`
+	IP      string         `json:"IP"`
+	Changes int64          `json:"Changes"`
+	Sources []SourceStatus `json:"Sources"`
+}
+
+// RecordStatus is the publishing state of a DDNS entry.
+type RecordStatus struct {
+	Key         string     `json:"Key"`
+	DomainName  string     `json:"DomainName"`
+	RR          string     `json:"RR"`
+	Type        string     `json:"Type"`
+	Line        string     `json:"Line,omitempty"`
+	Value       string     `json:"Value,omitempty"`
+	LastSuccess *time.Time `json:"LastSuccess,omitempty"`
+	LastError   string     `json:"LastError,omitempty"`
+	LastFailure *time.Time `json:"LastFailure,omitempty"`
+	NextRetry   *time.Time `json:"NextRetry,omitempty"`
+	Attempts    int64      `json:"Attempts"`
+	Successes   int64      `json:"Successes"`
+	Failures    int64      `json:"Failures"`
+	// Candidates is the health of the candidates of a failover record.
+	Candidates []CandidateStatus `json:"Candidates,omitempty"`
+}
+
+// DaemonStatus is the response of the status endpoint of the daemon.
+type DaemonStatus struct {
+	Addresses []AddressStatus `json:"Addresses"`
+	Records   []RecordStatus  `json:"Records"`
+}
+
+// sources keeps the last result of each IP address provider, and counts the
+// changes of the detected address.
+type sources struct {
+	mutex   sync.Mutex
+	order   []string
+	status  map[string]*SourceStatus
+	changes int64
+}
+
+func (s *sources) changed() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.changes++
+}
+
+func (s *sources) changeCount() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.changes
+}
+
+func (s *sources) record(source string, ip net.IP, err error, latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.status == nil {
+		s.status = map[string]*SourceStatus{}
+	}
+	status, ok := s.status[source]
+	if !ok {
+		status = &SourceStatus{Source: source}
+		s.status[source] = status
+		s.order = append(s.order, source)
+	}
+	status.Checked = time.Now()
+	status.Latency = latency.Seconds()
+	status.LatencySum += status.Latency
+	status.Requests++
+	if err != nil {
+		status.Error = err.Error()
+		status.Failures++
+	} else {
+		status.IP = ip.String()
+		status.Error = ""
+	}
+}
+
+func (s *sources) list() []SourceStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]SourceStatus, 0, len(s.order))
+	for _, source := range s.order {
+		result = append(result, *s.status[source])
+	}
+	return result
+}
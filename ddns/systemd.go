@@ -0,0 +1,75 @@
+package ddns
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kdiot/alidns-console/utility"
+)
+
+// sdNotify sends a state change to systemd with the sd_notify protocol, such
+// as "READY=1". It does nothing if the daemon is not run by systemd as a
+// Type=notify service.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// a name starting with '@' is an abstract socket, which the net package handles
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdReloading is the state telling systemd that the configuration is being
+// reloaded, a Type=notify-reload service must send it with the CLOCK_MONOTONIC
+// time.
+func sdReloading() string {
+	return "RELOADING=1\nMONOTONIC_USEC=" + strconv.FormatInt(monotonicUsec(), 10)
+}
+
+// sdWatchdogInterval returns how often systemd expects a "WATCHDOG=1" ping,
+// or 0 if the watchdog is not enabled for this process.
+func sdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// notifySystemd sends a state change to systemd, logging the failures.
+func (daemon *Daemon) notifySystemd(state string) {
+	if err := sdNotify(state); err != nil {
+		utility.Warningf("Failed to notify systemd of '%s': %s", state, err.Error())
+	}
+}
+
+// notifyStatus updates the status line shown by systemctl when the detected
+// IP addresses change.
+func (daemon *Daemon) notifyStatus() {
+	address := func(ip net.IP) string {
+		if ip == nil || ip.IsUnspecified() {
+			return "none"
+		}
+		return ip.String()
+	}
+	status := "IPv4: " + address(daemon.ipv4.Current()) +
+		", IPv6: " + address(daemon.ipv6.Current()) +
+		", records: " + strconv.Itoa(len(daemon.services))
+	if status != daemon.sdStatus {
+		daemon.sdStatus = status
+		daemon.notifySystemd("STATUS=" + status)
+	}
+}
@@ -0,0 +1,17 @@
+package ddns
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// monotonicUsec returns the CLOCK_MONOTONIC time in microseconds, which
+// systemd compares with the time it sent SIGHUP at.
+func monotonicUsec() int64 {
+	var ts syscall.Timespec
+	// CLOCK_MONOTONIC is 1
+	if _, _, errno := syscall.Syscall(syscall.SYS_CLOCK_GETTIME, 1, uintptr(unsafe.Pointer(&ts)), 0); errno != 0 {
+		return 0
+	}
+	return ts.Nano() / 1000
+}
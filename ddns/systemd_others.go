@@ -0,0 +1,8 @@
+//go:build !linux
+
+package ddns
+
+// systemd only runs on Linux.
+func monotonicUsec() int64 {
+	return 0
+}
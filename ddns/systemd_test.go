@@ -0,0 +1,106 @@
+//go:build !windows
+
+package ddns
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// notifySocket binds a datagram socket and points NOTIFY_SOCKET at it, like
+// systemd does for a Type=notify service.
+func notifySocket(t *testing.T) *net.UnixConn {
+	name := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", name)
+	return conn
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buffer := make([]byte, 4096)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buffer[:n])
+}
+
+func TestSdNotify(t *testing.T) {
+	conn := notifySocket(t)
+	daemon := &Daemon{ipv4: NewExternalIPv4(nil), ipv6: NewExternalIPv6(nil)}
+
+	daemon.notifySystemd("READY=1")
+	if got := receive(t, conn); got != "READY=1" {
+		t.Errorf("got '%s', want 'READY=1'", got)
+	}
+
+	daemon.notifyStatus()
+	if got, want := receive(t, conn), "STATUS=IPv4: none, IPv6: none, records: 0"; got != want {
+		t.Errorf("got '%s', want '%s'", got, want)
+	}
+	// the status is only sent when it changes
+	daemon.notifyStatus()
+
+	daemon.notifySystemd("WATCHDOG=1")
+	if got := receive(t, conn); got != "WATCHDOG=1" {
+		t.Errorf("got '%s', want 'WATCHDOG=1'", got)
+	}
+}
+
+func TestSdNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Errorf("got error %s without systemd", err.Error())
+	}
+}
+
+func TestSdReloading(t *testing.T) {
+	conn := notifySocket(t)
+
+	before := monotonicUsec()
+	if err := sdNotify(sdReloading()); err != nil {
+		t.Fatal(err)
+	}
+	after := monotonicUsec()
+
+	lines := strings.Split(receive(t, conn), "\n")
+	if len(lines) != 2 || lines[0] != "RELOADING=1" || !strings.HasPrefix(lines[1], "MONOTONIC_USEC=") {
+		t.Fatalf("got %q, want RELOADING=1 and MONOTONIC_USEC", lines)
+	}
+	usec, err := strconv.ParseInt(strings.TrimPrefix(lines[1], "MONOTONIC_USEC="), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != 0 && (usec < before || usec > after) {
+		t.Errorf("MONOTONIC_USEC=%d is not between %d and %d", usec, before, after)
+	}
+}
+
+func TestSdWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	if got := sdWatchdogInterval(); got != 30*time.Second {
+		t.Errorf("got %s, want 30s", got)
+	}
+
+	// the watchdog is meant for another process
+	t.Setenv("WATCHDOG_PID", "1")
+	if got := sdWatchdogInterval(); got != 0 {
+		t.Errorf("got %s for another process, want 0", got)
+	}
+
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "")
+	if got := sdWatchdogInterval(); got != 0 {
+		t.Errorf("got %s without a watchdog, want 0", got)
+	}
+}
@@ -1,12 +1,15 @@
 package ddns
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/kdiot/alidns-console/utility"
 )
@@ -43,12 +46,27 @@ func RegExpIPv6() *regexp.Regexp {
 	return result
 }
 
-func GetPublicIPv4(url string, re *regexp.Regexp) (string, error) {
+// ipProviderTimeout bounds a request to a provider of the public address, the
+// next provider is tried once it expires.
+const ipProviderTimeout = 10 * time.Second
 
-	response, err := http.Get(url)
+var ipProviderClient = &http.Client{Timeout: ipProviderTimeout}
+
+func getPublicIP(ctx context.Context, url string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ipProviderClient.Do(request)
+}
+
+func GetPublicIPv4(ctx context.Context, url string, re *regexp.Regexp) (string, error) {
+
+	response, err := getPublicIP(ctx, url)
 	if err != nil {
 		return "", err
 	}
+	defer response.Body.Close()
 
 	var content string
 	if data, err := io.ReadAll(response.Body); err != nil {
@@ -68,12 +86,13 @@ func GetPublicIPv4(url string, re *regexp.Regexp) (string, error) {
 	}
 }
 
-func GetPublicIPv6(url string, re *regexp.Regexp) (string, error) {
+func GetPublicIPv6(ctx context.Context, url string, re *regexp.Regexp) (string, error) {
 
-	response, err := http.Get(url)
+	response, err := getPublicIP(ctx, url)
 	if err != nil {
 		return "", err
 	}
+	defer response.Body.Close()
 
 	var content string
 	if data, err := io.ReadAll(response.Body); err != nil {
@@ -103,36 +122,104 @@ func GetLocalIP(address string) *net.IP {
 	return &addr.IP
 }
 
+// InterfaceIPv4 returns a global unicast IPv4 address of the network interface.
+func InterfaceIPv4(name string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil && ipnet.IP.IsGlobalUnicast() {
+			return ipnet.IP.To4(), nil
+		}
+	}
+	return nil, fmt.Errorf("the interface '%s' has no global IPv4 address", name)
+}
+
+// InterfaceIPv6 returns a global unicast IPv6 address of the network
+// interface, preferring public addresses to unique local ones.
+func InterfaceIPv6(name string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var local net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.To4() != nil || !ipnet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if !ipnet.IP.IsPrivate() {
+			return ipnet.IP, nil
+		}
+		if local == nil {
+			local = ipnet.IP
+		}
+	}
+	if local != nil {
+		return local, nil
+	}
+	return nil, fmt.Errorf("the interface '%s' has no global IPv6 address", name)
+}
+
+// interfacePrefix is the provider prefix of ExternalIPv4 and ExternalIPv6
+// that reads the address of a local network interface.
+const interfacePrefix = "if:"
+
+func isInterfaceProvider(provider string) bool {
+	return strings.HasPrefix(provider, interfacePrefix)
+}
+
 type ExternalIP interface {
-	GetIP(url string) (net.IP, error)
-	Refresh() (net.IP, bool)
+	GetIP(ctx context.Context, url string) (net.IP, error)
+	Refresh(ctx context.Context) (net.IP, bool)
+	Current() net.IP
+	Sources() []SourceStatus
+	Changes() int64
 }
 
 type ExternalIPv4 struct {
 	IP        net.IP
 	re        *regexp.Regexp
 	providers []string
+	sources   sources
 }
 
-func (ipv4 *ExternalIPv4) GetIP(url string) (net.IP, error) {
-	if ip, err := GetPublicIPv4(url, ipv4.re); err != nil {
+func (ipv4 *ExternalIPv4) GetIP(ctx context.Context, url string) (net.IP, error) {
+	if isInterfaceProvider(url) {
+		return InterfaceIPv4(strings.TrimPrefix(url, interfacePrefix))
+	}
+	if ip, err := GetPublicIPv4(ctx, url, ipv4.re); err != nil {
 		return nil, err
 	} else {
 		return net.ParseIP(ip), nil
 	}
 }
 
-func (ipv4 *ExternalIPv4) Refresh() (net.IP, bool) {
+func (ipv4 *ExternalIPv4) Refresh(ctx context.Context) (net.IP, bool) {
 	count := len(ipv4.providers)
-	for i := 0; i < count; i++ {
+	for i := 0; i < count && ctx.Err() == nil; i++ {
 		url := ipv4.providers[0]
-		if ip, err := ipv4.GetIP(url); err != nil {
+		begin := time.Now()
+		ip, err := ipv4.GetIP(ctx, url)
+		ipv4.sources.record(url, ip, err, time.Since(begin))
+		if err != nil {
 			ipv4.providers = append(ipv4.providers[1:], url)
 			utility.Errorf("Failed to obtain public IPv4 address.[vender:%s, error:%s]", url, err.Error())
 			continue
 		} else {
 			if !ip.Equal(ipv4.IP) {
 				ipv4.IP = ip
+				ipv4.sources.changed()
 				return ipv4.IP, true
 			} else {
 				return ipv4.IP, false
@@ -142,6 +229,18 @@ func (ipv4 *ExternalIPv4) Refresh() (net.IP, bool) {
 	return ipv4.IP, false
 }
 
+func (ipv4 *ExternalIPv4) Current() net.IP {
+	return ipv4.IP
+}
+
+func (ipv4 *ExternalIPv4) Sources() []SourceStatus {
+	return ipv4.sources.list()
+}
+
+func (ipv4 *ExternalIPv4) Changes() int64 {
+	return ipv4.sources.changeCount()
+}
+
 func NewExternalIPv4(providers []string) *ExternalIPv4 {
 	ipv4 := &ExternalIPv4{
 		IP: net.IPv4zero,
@@ -165,12 +264,15 @@ type ExternalIPv6 struct {
 	IP        net.IP
 	re        *regexp.Regexp
 	providers []string
+	sources   sources
 }
 
-func (ipv6 *ExternalIPv6) GetIP(address string) (net.IP, error) {
+func (ipv6 *ExternalIPv6) GetIP(ctx context.Context, address string) (net.IP, error) {
 
-	if address[:4] == "http" {
-		if ip, err := GetPublicIPv6(address, ipv6.re); err != nil {
+	if isInterfaceProvider(address) {
+		return InterfaceIPv6(strings.TrimPrefix(address, interfacePrefix))
+	} else if address[:4] == "http" {
+		if ip, err := GetPublicIPv6(ctx, address, ipv6.re); err != nil {
 			return nil, err
 		} else {
 			return net.ParseIP(ip), nil
@@ -186,16 +288,20 @@ func (ipv6 *ExternalIPv6) GetIP(address string) (net.IP, error) {
 	}
 }
 
-func (ipv6 *ExternalIPv6) Refresh() (net.IP, bool) {
+func (ipv6 *ExternalIPv6) Refresh(ctx context.Context) (net.IP, bool) {
 	count := len(ipv6.providers)
-	for i := 0; i < count; i++ {
+	for i := 0; i < count && ctx.Err() == nil; i++ {
 		url := ipv6.providers[0]
-		if ip, err := ipv6.GetIP(url); err != nil {
+		begin := time.Now()
+		ip, err := ipv6.GetIP(ctx, url)
+		ipv6.sources.record(url, ip, err, time.Since(begin))
+		if err != nil {
 			ipv6.providers = append(ipv6.providers[1:], url)
 			continue
 		} else {
 			if !ip.Equal(ipv6.IP) {
 				ipv6.IP = ip
+				ipv6.sources.changed()
 				return ipv6.IP, true
 			} else {
 				return ipv6.IP, false
@@ -205,6 +311,18 @@ func (ipv6 *ExternalIPv6) Refresh() (net.IP, bool) {
 	return ipv6.IP, false
 }
 
+func (ipv6 *ExternalIPv6) Current() net.IP {
+	return ipv6.IP
+}
+
+func (ipv6 *ExternalIPv6) Sources() []SourceStatus {
+	return ipv6.sources.list()
+}
+
+func (ipv6 *ExternalIPv6) Changes() int64 {
+	return ipv6.sources.changeCount()
+}
+
 func NewExternalIPv6(providers []string) *ExternalIPv6 {
 	ipv6 := &ExternalIPv6{
 		IP: net.IPv6zero,
@@ -4,11 +4,11 @@ go 1.20
 
 require (
 	github.com/alibabacloud-go/alidns-20150109/v4 v4.0.0
+	github.com/mattn/go-runewidth v0.0.13
 	github.com/olekukonko/tablewriter v0.0.5
 )
 
 require (
-	github.com/mattn/go-runewidth v0.0.13 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
 	github.com/stretchr/testify v1.8.0 // indirect
 )
@@ -1,10 +1,8 @@
 package main
 
 import (
-	"fmt"
 	"os"
 
-	"github.com/alibabacloud-go/tea/tea"
 	"github.com/kdiot/alidns-console/console"
 )
 
@@ -18,75 +16,59 @@ func Version() string {
 
 func main() {
 
-	usage := func() {
-		fmt.Println("Usage:  alidns COMMAND")
-		fmt.Println("Commands:")
-		fmt.Println("  ls          List domain name records")
-		fmt.Println("  add         Create a new domain name record")
-		fmt.Println("  mod         Modify domain name record by RecordId")
-		fmt.Println("  rm          Remove given domain name record by RecordId")
-		fmt.Println("  ddns        Automatically update the domain name A record when a change in the external IP address is detected")
-		fmt.Println("  help        Print help information for specific commands, such as: ls, add, etc.")
-		fmt.Println("  version     Show the alidns version information")
-	}
+	registry := console.NewRegistry(Version())
 
-	if len(os.Args) < 2 {
-		fmt.Println("Error! Not enough command line arguments.")
-		usage()
-		return
+	// the commands added with AddFunc also run in the shell, which makes a new
+	// command for every line
+	ls := func() console.Command { return console.NewCmdLs() }
+	add := func() console.Command { return console.NewCmdAdd() }
+	mod := func() console.Command { return console.NewCmdMod() }
+	rm := func() console.Command { return console.NewCmdRm() }
+	batch := func() console.Command { return console.NewCmdBatch() }
+	slbCmd := func(action string) func() console.Command {
+		return func() console.Command { return console.NewCmdSlb(action) }
 	}
 
-	commands := map[string]console.Command{}
-	if cmd := console.NewCmdLs(); cmd != nil {
-		commands[cmd.Name()] = cmd
-	}
-	if cmd := console.NewCmdAdd(); cmd != nil {
-		commands[cmd.Name()] = cmd
-	}
-	if cmd := console.NewCmdMod(); cmd != nil {
-		commands[cmd.Name()] = cmd
-	}
-	if cmd := console.NewCmdRm(); cmd != nil {
-		commands[cmd.Name()] = cmd
-	}
-	if cmd := console.NewCmdDdns(); cmd != nil {
-		commands[cmd.Name()] = cmd
-	}
+	record := registry.Group("record", "Manage the domain name records: ls, add, mod, rm, batch, export")
+	record.AddFunc(ls, "List domain name records")
+	record.AddFunc(add, "Create a new domain name record")
+	record.AddFunc(mod, "Modify domain name record by RecordId")
+	record.AddFunc(rm, "Remove given domain name record by RecordId")
+	record.AddFunc(batch, "Apply the add, mod, rm, enable and disable operations of a file or stdin")
+	record.AddFunc(func() console.Command { return console.NewCmdExport() }, "Write the domain name records as add operations of a batch")
+
+	domain := registry.Group("domain", "Manage the domains of the account: ls")
+	domain.AddFunc(func() console.Command { return console.NewCmdDomain() }, "List the domains of the account")
 
-	name := os.Args[1]
-	if cmd, ok := commands[name]; ok {
-		if err := cmd.Parse(os.Args[2:]); err != nil {
-			fmt.Printf("Illegal parameter of command '%s': %s\n", name, err.Error())
-			return
-		}
-		if err := cmd.Check(); err != nil {
-			fmt.Printf("Command line '%s' parameter check failed: %s\n", name, err.Error())
-			return
-		}
-		if err := cmd.Execute(); err != nil {
-			var msg string
-			if e, ok := err.(*tea.SDKError); ok {
-				msg = fmt.Sprintf("ErrCode: %s, %s", *e.Code, *e.Message)
-			} else {
-				msg = err.Error()
-			}
-			fmt.Printf("Failed to execute '%s' command! [%s.]\n", name, msg)
-		}
-	} else if name == "help" {
-		if len(os.Args) > 2 {
-			cmd, ok := commands[os.Args[2]]
-			if !ok {
-				fmt.Printf("Error! Could not find help for command '%s'.\n", os.Args[2])
-				usage()
-				return
-			}
-			cmd.Usage()
-		} else {
-			usage()
-		}
-	} else if name == "version" {
-		fmt.Println(Version())
-	} else {
-		usage()
+	registry.AddFunc(func() console.Command { return console.NewCmdBackup() }, "Save every domain name record to a timestamped snapshot")
+	registry.AddFunc(func() console.Command { return console.NewCmdRestore() }, "Revert the domain name records to a snapshot")
+
+	slb := registry.Group("slb", "Manage weighted round-robin of the records of a subdomain: ls, enable, disable, weight")
+	slb.AddFunc(slbCmd("ls"), "List the subdomains whose records can be weighted, and the weights of the records of -rr")
+	slb.AddFunc(slbCmd("enable"), "Enable weighted round-robin of the records of -rr")
+	slb.AddFunc(slbCmd("disable"), "Disable weighted round-robin of the records of -rr")
+	slb.AddFunc(slbCmd("weight"), "Set the weight of the record -id to -weight")
+
+	lines := registry.Group("lines", "List the resolution lines: ls")
+	lines.AddFunc(func() console.Command { return console.NewCmdLines() }, "List the resolution lines, the CODE is the value of -line")
+
+	registry.Add(console.NewCmdShell(registry), "Run the commands interactively in a session keeping the client")
+
+	ddns := registry.Group("ddns", "Automatically update the domain name records when the external IP addresses change: run (the default), status, serve")
+	ddns.Add(console.NewCmdDdns("run"), "Update the domain name records when the IP addresses change")
+	ddns.Add(console.NewCmdDdns("status"), "Show the status of a running daemon from its status API")
+	ddns.Add(console.NewCmdDdns("serve"), "Update the records with the addresses submitted to a dyndns2 compatible server")
+	// 'ddns -conf FILE' runs the daemon, as it did before the subcommands
+	ddns.Default("run")
+
+	registry.Add(console.NewCmdCompletion(), "Print the completion script of a shell: bash, zsh, fish")
+	registry.Add(console.NewCmdComplete(registry), "")
+
+	// the record commands used to be top-level commands, they are kept for
+	// the scripts running them
+	for _, create := range []func() console.Command{ls, add, mod, rm, batch} {
+		registry.AddFunc(create, "")
 	}
+
+	os.Exit(registry.Run(os.Args[1:]))
 }
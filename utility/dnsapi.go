@@ -14,6 +14,12 @@ import (
 
 type DomainRecord = alidns.DescribeDomainRecordsResponseBodyDomainRecordsRecord
 
+// Domain is a domain of the account.
+type Domain = alidns.DescribeDomainsResponseBodyDomainsDomain
+
+// SlbSubDomain is a subdomain whose records can be answered by weighted round-robin.
+type SlbSubDomain = alidns.DescribeDNSSLBSubDomainsResponseBodySlbSubDomainsSlbSubDomain
+
 var RecordTypes = []string{
 	"A",
 	"NS",
@@ -36,6 +42,27 @@ func IsTypeValid(v string) bool {
 	return false
 }
 
+// DefaultLine is the resolution line of the records answered to the clients
+// not matched by a more specific line.
+const DefaultLine = "default"
+
+// LineValue returns the resolution line, DefaultLine if it is not set.
+func LineValue(line *string) string {
+	if line == nil || *line == "" {
+		return DefaultLine
+	}
+	return *line
+}
+
+// Line is a resolution line, either a line supported by Alidns or a custom
+// line of the domain.
+type Line struct {
+	Code   string
+	Name   string
+	Father string
+	Custom bool
+}
+
 type QueryInfo struct {
 	RR     *string
 	Type   *string
@@ -45,8 +72,34 @@ type QueryInfo struct {
 
 type AlidnsApi struct {
 	DomainName string
-	client     *alidns.Client
-	options    *util.RuntimeOptions
+	// DryRun logs the requests that would modify domain name records instead
+	// of sending them, requests that only read records are still sent.
+	DryRun  bool
+	client  *alidns.Client
+	options *util.RuntimeOptions
+}
+
+// dryRunRecordId is the RecordId of the records "added" in dry-run mode.
+const dryRunRecordId = "DRY-RUN"
+
+func (api *AlidnsApi) record(action string, request fmt.Stringer) {
+	Infof("[DRY-RUN] %s would be sent: %s", action, strings.Join(strings.Fields(request.String()), " "))
+}
+
+// trace logs the requests sent at the debug level.
+func (api *AlidnsApi) trace(action string, request fmt.Stringer) {
+	if logLevel <= LOG_DEBUG {
+		Debugf("%s: %s", action, strings.Join(strings.Fields(request.String()), " "))
+	}
+}
+
+// endpoint is the Alidns API endpoint set by SetEndpoint.
+var endpoint string
+
+// SetEndpoint makes the new clients send their requests to the endpoint
+// instead of the one in ALIDNS_ENDPOINT or the default one.
+func SetEndpoint(v string) {
+	endpoint = v
 }
 
 func NewAlidnsApi(domainName string, accessKeyId string, accessKeySecret string) (*AlidnsApi, error) {
@@ -56,7 +109,9 @@ func NewAlidnsApi(domainName string, accessKeyId string, accessKeySecret string)
 		AccessKeySecret: &accessKeySecret,
 	}
 
-	if v := os.Getenv("ALIDNS_ENDPOINT"); v != "" {
+	if endpoint != "" {
+		config.Endpoint = tea.String(endpoint)
+	} else if v := os.Getenv("ALIDNS_ENDPOINT"); v != "" {
 		config.Endpoint = tea.String(v)
 	} else {
 		config.Endpoint = tea.String("alidns.cn-hangzhou.aliyuncs.com")
@@ -93,6 +148,7 @@ func (api *AlidnsApi) describeDomainRecords(request *alidns.DescribeDomainRecord
 				e = r
 			}
 		}()
+		api.trace("DescribeDomainRecords", request)
 		return api.client.DescribeDomainRecordsWithOptions(request, api.options)
 	}()
 
@@ -110,6 +166,7 @@ func (api *AlidnsApi) describeDomainRecordInfo(request *alidns.DescribeDomainRec
 				e = r
 			}
 		}()
+		api.trace("DescribeDomainRecordInfo", request)
 		return api.client.DescribeDomainRecordInfoWithOptions(request, api.options)
 	}()
 
@@ -120,6 +177,12 @@ func (api *AlidnsApi) updateDomainRecord(request *alidns.UpdateDomainRecordReque
 	if request == nil {
 		return nil, errors.New("AlidnsApi.updateDomainRecord: The parameter request cannot be nil")
 	}
+	if api.DryRun {
+		api.record("UpdateDomainRecord", request)
+		return &alidns.UpdateDomainRecordResponse{
+			Body: &alidns.UpdateDomainRecordResponseBody{RecordId: request.RecordId},
+		}, nil
+	}
 	response, err := func() (result *alidns.UpdateDomainRecordResponse, e error) {
 		defer func() {
 			if r := tea.Recover(recover()); r != nil {
@@ -127,6 +190,7 @@ func (api *AlidnsApi) updateDomainRecord(request *alidns.UpdateDomainRecordReque
 				e = r
 			}
 		}()
+		api.trace("UpdateDomainRecord", request)
 		return api.client.UpdateDomainRecordWithOptions(request, api.options)
 	}()
 
@@ -138,6 +202,12 @@ func (api *AlidnsApi) addDomainRecord(request *alidns.AddDomainRecordRequest) (*
 		return nil, errors.New("AlidnsApi.addDomainRecord: The parameter request cannot be nil")
 	}
 	request.DomainName = &api.DomainName
+	if api.DryRun {
+		api.record("AddDomainRecord", request)
+		return &alidns.AddDomainRecordResponse{
+			Body: &alidns.AddDomainRecordResponseBody{RecordId: tea.String(dryRunRecordId)},
+		}, nil
+	}
 	response, err := func() (result *alidns.AddDomainRecordResponse, e error) {
 		defer func() {
 			if r := tea.Recover(recover()); r != nil {
@@ -145,6 +215,7 @@ func (api *AlidnsApi) addDomainRecord(request *alidns.AddDomainRecordRequest) (*
 				e = r
 			}
 		}()
+		api.trace("AddDomainRecord", request)
 		return api.client.AddDomainRecordWithOptions(request, api.options)
 	}()
 
@@ -155,6 +226,12 @@ func (api *AlidnsApi) deleteDomainRecord(request *alidns.DeleteDomainRecordReque
 	if request == nil {
 		return nil, errors.New("AlidnsApi.deleteDomainRecord: The parameter request cannot be nil")
 	}
+	if api.DryRun {
+		api.record("DeleteDomainRecord", request)
+		return &alidns.DeleteDomainRecordResponse{
+			Body: &alidns.DeleteDomainRecordResponseBody{RecordId: request.RecordId},
+		}, nil
+	}
 	response, err := func() (result *alidns.DeleteDomainRecordResponse, e error) {
 		defer func() {
 			if r := tea.Recover(recover()); r != nil {
@@ -162,12 +239,203 @@ func (api *AlidnsApi) deleteDomainRecord(request *alidns.DeleteDomainRecordReque
 				e = r
 			}
 		}()
+		api.trace("DeleteDomainRecord", request)
 		return api.client.DeleteDomainRecordWithOptions(request, api.options)
 	}()
 
 	return response, err
 }
 
+func (api *AlidnsApi) updateDomainRecordRemark(request *alidns.UpdateDomainRecordRemarkRequest) (*alidns.UpdateDomainRecordRemarkResponse, error) {
+	if request == nil {
+		return nil, errors.New("AlidnsApi.updateDomainRecordRemark: The parameter request cannot be nil")
+	}
+	if api.DryRun {
+		api.record("UpdateDomainRecordRemark", request)
+		return &alidns.UpdateDomainRecordRemarkResponse{
+			Body: &alidns.UpdateDomainRecordRemarkResponseBody{},
+		}, nil
+	}
+	response, err := func() (result *alidns.UpdateDomainRecordRemarkResponse, e error) {
+		defer func() {
+			if r := tea.Recover(recover()); r != nil {
+				result = nil
+				e = r
+			}
+		}()
+		api.trace("UpdateDomainRecordRemark", request)
+		return api.client.UpdateDomainRecordRemarkWithOptions(request, api.options)
+	}()
+
+	return response, err
+}
+
+func (api *AlidnsApi) describeDomains(request *alidns.DescribeDomainsRequest) (*alidns.DescribeDomainsResponse, error) {
+	if request == nil {
+		request = &alidns.DescribeDomainsRequest{}
+	}
+	response, err := func() (result *alidns.DescribeDomainsResponse, e error) {
+		defer func() {
+			if r := tea.Recover(recover()); r != nil {
+				result = nil
+				e = r
+			}
+		}()
+		api.trace("DescribeDomains", request)
+		return api.client.DescribeDomainsWithOptions(request, api.options)
+	}()
+
+	return response, err
+}
+
+func (api *AlidnsApi) describeDNSSLBSubDomains(request *alidns.DescribeDNSSLBSubDomainsRequest) (*alidns.DescribeDNSSLBSubDomainsResponse, error) {
+	if request == nil {
+		request = &alidns.DescribeDNSSLBSubDomainsRequest{}
+	}
+	request.DomainName = &api.DomainName
+	response, err := func() (result *alidns.DescribeDNSSLBSubDomainsResponse, e error) {
+		defer func() {
+			if r := tea.Recover(recover()); r != nil {
+				result = nil
+				e = r
+			}
+		}()
+		api.trace("DescribeDNSSLBSubDomains", request)
+		return api.client.DescribeDNSSLBSubDomainsWithOptions(request, api.options)
+	}()
+
+	return response, err
+}
+
+func (api *AlidnsApi) setDNSSLBStatus(request *alidns.SetDNSSLBStatusRequest) (*alidns.SetDNSSLBStatusResponse, error) {
+	if request == nil {
+		return nil, errors.New("AlidnsApi.setDNSSLBStatus: The parameter request cannot be nil")
+	}
+	request.DomainName = &api.DomainName
+	if api.DryRun {
+		api.record("SetDNSSLBStatus", request)
+		return &alidns.SetDNSSLBStatusResponse{
+			Body: &alidns.SetDNSSLBStatusResponseBody{Open: request.Open},
+		}, nil
+	}
+	response, err := func() (result *alidns.SetDNSSLBStatusResponse, e error) {
+		defer func() {
+			if r := tea.Recover(recover()); r != nil {
+				result = nil
+				e = r
+			}
+		}()
+		api.trace("SetDNSSLBStatus", request)
+		return api.client.SetDNSSLBStatusWithOptions(request, api.options)
+	}()
+
+	return response, err
+}
+
+func (api *AlidnsApi) updateDNSSLBWeight(request *alidns.UpdateDNSSLBWeightRequest) (*alidns.UpdateDNSSLBWeightResponse, error) {
+	if request == nil {
+		return nil, errors.New("AlidnsApi.updateDNSSLBWeight: The parameter request cannot be nil")
+	}
+	if api.DryRun {
+		api.record("UpdateDNSSLBWeight", request)
+		return &alidns.UpdateDNSSLBWeightResponse{
+			Body: &alidns.UpdateDNSSLBWeightResponseBody{RecordId: request.RecordId, Weight: request.Weight},
+		}, nil
+	}
+	response, err := func() (result *alidns.UpdateDNSSLBWeightResponse, e error) {
+		defer func() {
+			if r := tea.Recover(recover()); r != nil {
+				result = nil
+				e = r
+			}
+		}()
+		api.trace("UpdateDNSSLBWeight", request)
+		return api.client.UpdateDNSSLBWeightWithOptions(request, api.options)
+	}()
+
+	return response, err
+}
+
+func (api *AlidnsApi) setDomainRecordStatus(request *alidns.SetDomainRecordStatusRequest) (*alidns.SetDomainRecordStatusResponse, error) {
+	if request == nil {
+		return nil, errors.New("AlidnsApi.setDomainRecordStatus: The parameter request cannot be nil")
+	}
+	if api.DryRun {
+		api.record("SetDomainRecordStatus", request)
+		return &alidns.SetDomainRecordStatusResponse{
+			Body: &alidns.SetDomainRecordStatusResponseBody{RecordId: request.RecordId, Status: request.Status},
+		}, nil
+	}
+	response, err := func() (result *alidns.SetDomainRecordStatusResponse, e error) {
+		defer func() {
+			if r := tea.Recover(recover()); r != nil {
+				result = nil
+				e = r
+			}
+		}()
+		api.trace("SetDomainRecordStatus", request)
+		return api.client.SetDomainRecordStatusWithOptions(request, api.options)
+	}()
+
+	return response, err
+}
+
+func (api *AlidnsApi) describeSupportLines(request *alidns.DescribeSupportLinesRequest) (*alidns.DescribeSupportLinesResponse, error) {
+	if request == nil {
+		request = &alidns.DescribeSupportLinesRequest{}
+	}
+	request.DomainName = &api.DomainName
+	response, err := func() (result *alidns.DescribeSupportLinesResponse, e error) {
+		defer func() {
+			if r := tea.Recover(recover()); r != nil {
+				result = nil
+				e = r
+			}
+		}()
+		api.trace("DescribeSupportLines", request)
+		return api.client.DescribeSupportLinesWithOptions(request, api.options)
+	}()
+
+	return response, err
+}
+
+func (api *AlidnsApi) describeCustomLines(request *alidns.DescribeCustomLinesRequest) (*alidns.DescribeCustomLinesResponse, error) {
+	if request == nil {
+		request = &alidns.DescribeCustomLinesRequest{}
+	}
+	request.DomainName = &api.DomainName
+	response, err := func() (result *alidns.DescribeCustomLinesResponse, e error) {
+		defer func() {
+			if r := tea.Recover(recover()); r != nil {
+				result = nil
+				e = r
+			}
+		}()
+		api.trace("DescribeCustomLines", request)
+		return api.client.DescribeCustomLinesWithOptions(request, api.options)
+	}()
+
+	return response, err
+}
+
+func (api *AlidnsApi) describeSubDomainRecords(request *alidns.DescribeSubDomainRecordsRequest) (*alidns.DescribeSubDomainRecordsResponse, error) {
+	if request == nil {
+		return nil, errors.New("AlidnsApi.describeSubDomainRecords: The parameter request cannot be nil")
+	}
+	response, err := func() (result *alidns.DescribeSubDomainRecordsResponse, e error) {
+		defer func() {
+			if r := tea.Recover(recover()); r != nil {
+				result = nil
+				e = r
+			}
+		}()
+		api.trace("DescribeSubDomainRecords", request)
+		return api.client.DescribeSubDomainRecordsWithOptions(request, api.options)
+	}()
+
+	return response, err
+}
+
 func (api *AlidnsApi) Query(query *QueryInfo) ([]*DomainRecord, error) {
 	request := &alidns.DescribeDomainRecordsRequest{
 		RRKeyWord:  query.RR,
@@ -227,14 +495,31 @@ func (api *AlidnsApi) Retrieve(recordId string) (*DomainRecord, error) {
 
 func (api *AlidnsApi) Add(record *DomainRecord) (*DomainRecord, error) {
 	response, err := api.addDomainRecord(&alidns.AddDomainRecordRequest{
-		RR:    record.RR,
-		TTL:   record.TTL,
-		Type:  record.Type,
-		Value: record.Value,
+		RR:       record.RR,
+		TTL:      record.TTL,
+		Type:     record.Type,
+		Value:    record.Value,
+		Line:     record.Line,
+		Priority: record.Priority,
 	})
 
 	if err != nil {
 		return nil, err
+	} else if api.DryRun {
+		ttl := record.TTL
+		if ttl == nil {
+			ttl = tea.Int64(600)
+		}
+		return &DomainRecord{
+			DomainName: tea.String(api.DomainName),
+			RecordId:   response.Body.RecordId,
+			RR:         record.RR,
+			Type:       record.Type,
+			Value:      record.Value,
+			TTL:        ttl,
+			Line:       tea.String(LineValue(record.Line)),
+			Status:     tea.String("ENABLE"),
+		}, nil
 	} else {
 		return api.Retrieve(*response.Body.RecordId)
 	}
@@ -247,6 +532,8 @@ func (api *AlidnsApi) Update(record *DomainRecord) error {
 		TTL:      record.TTL,
 		Type:     record.Type,
 		Value:    record.Value,
+		Line:     record.Line,
+		Priority: record.Priority,
 	})
 	return err
 }
@@ -268,6 +555,7 @@ func (api *AlidnsApi) AutoUpdate(record *DomainRecord) error {
 			TTL:      record.TTL,
 			Type:     record.Type,
 			Value:    record.Value,
+			Line:     record.Line,
 		}
 		_, err := api.updateDomainRecord(request)
 		if err != nil {
@@ -290,21 +578,30 @@ func (api *AlidnsApi) AutoUpdate(record *DomainRecord) error {
 		}
 	}
 
-	response, err := api.describeDomainRecords(&alidns.DescribeDomainRecordsRequest{
-		Type:      record.Type,
-		RRKeyWord: record.RR,
+	records, err := api.Query(&QueryInfo{
+		RR:   record.RR,
+		Type: record.Type,
+		Line: tea.String(LineValue(record.Line)),
 	})
 	if err != nil {
 		return err
 	}
-	if len(response.Body.DomainRecords.Record) > 0 {
-		old := response.Body.DomainRecords.Record[0]
+	var old *DomainRecord
+	for _, r := range records {
+		// RRKeyWord is a fuzzy match, so compare the RR and the line exactly
+		if tea.StringValue(r.RR) == tea.StringValue(record.RR) && LineValue(r.Line) == LineValue(record.Line) {
+			old = r
+			break
+		}
+	}
+	if old != nil {
 		_, err := api.updateDomainRecord(&alidns.UpdateDomainRecordRequest{
 			RR:       record.RR,
 			RecordId: old.RecordId,
 			TTL:      record.TTL,
 			Type:     record.Type,
 			Value:    record.Value,
+			Line:     old.Line,
 		})
 		if err != nil {
 			if e, ok := err.(*tea.SDKError); ok {
@@ -331,11 +628,15 @@ func (api *AlidnsApi) AutoUpdate(record *DomainRecord) error {
 			TTL:   record.TTL,
 			Type:  record.Type,
 			Value: record.Value,
+			Line:  record.Line,
 		})
 		if err != nil {
 			return err
 		}
 		record.RecordId = response.Body.RecordId
+		if api.DryRun {
+			return nil
+		}
 		new, _ := api.Retrieve(*response.Body.RecordId)
 		if new != nil {
 			*record = *new
@@ -344,6 +645,99 @@ func (api *AlidnsApi) AutoUpdate(record *DomainRecord) error {
 	}
 }
 
+func (api *AlidnsApi) SetRemark(recordId string, remark string) error {
+	_, err := api.updateDomainRecordRemark(&alidns.UpdateDomainRecordRemarkRequest{
+		RecordId: &recordId,
+		Remark:   &remark,
+	})
+	return err
+}
+
+// AutoUpdateSet makes the records of the RR, Type and Line of the template hold
+// exactly the given values. Missing values are added, or written over the
+// records that no longer hold a wanted value, and the extra records are
+// deleted. Only the records whose Remark is the owner tag are modified or
+// deleted, the records created are tagged with it. It returns the records
+// holding the values.
+func (api *AlidnsApi) AutoUpdateSet(template *DomainRecord, values []string, owner string) ([]*DomainRecord, error) {
+	records, err := api.Query(&QueryInfo{RR: template.RR, Type: template.Type, Line: tea.String(LineValue(template.Line))})
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, value := range values {
+		wanted[value] = true
+	}
+
+	var result, spare []*DomainRecord
+	for _, record := range records {
+		// RRKeyWord is a fuzzy match, so compare the RR and the line exactly
+		if tea.StringValue(record.RR) != tea.StringValue(template.RR) || LineValue(record.Line) != LineValue(template.Line) {
+			continue
+		}
+		value := tea.StringValue(record.Value)
+		if wanted[value] {
+			delete(wanted, value)
+			result = append(result, record)
+		} else if tea.StringValue(record.Remark) == owner {
+			spare = append(spare, record)
+		}
+	}
+
+	for _, value := range values {
+		if !wanted[value] {
+			continue
+		}
+		delete(wanted, value)
+		record := &DomainRecord{
+			RR:    template.RR,
+			Type:  template.Type,
+			TTL:   template.TTL,
+			Line:  template.Line,
+			Value: tea.String(value),
+		}
+		if len(spare) > 0 {
+			record.RecordId = spare[0].RecordId
+			spare = spare[1:]
+			if err := api.Update(record); err != nil {
+				return nil, err
+			}
+		} else {
+			added, err := api.Add(record)
+			if err != nil {
+				return nil, err
+			}
+			if err := api.SetRemark(tea.StringValue(added.RecordId), owner); err != nil {
+				return nil, err
+			}
+			record = added
+		}
+		record.Remark = tea.String(owner)
+		result = append(result, record)
+	}
+
+	for _, record := range spare {
+		if err := api.Delete(tea.StringValue(record.RecordId)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// SetStatus enables or disables the record, a disabled record is not answered.
+func (api *AlidnsApi) SetStatus(recordId string, enable bool) error {
+	status := "Disable"
+	if enable {
+		status = "Enable"
+	}
+	_, err := api.setDomainRecordStatus(&alidns.SetDomainRecordStatusRequest{
+		RecordId: &recordId,
+		Status:   &status,
+	})
+	return err
+}
+
 func (api *AlidnsApi) Delete(recordId string) error {
 	_, err := api.deleteDomainRecord(&alidns.DeleteDomainRecordRequest{
 		RecordId: &recordId,
@@ -351,6 +745,219 @@ func (api *AlidnsApi) Delete(recordId string) error {
 	return err
 }
 
+// Domains lists the domains of the account, those containing the keyword if
+// it is not empty.
+func (api *AlidnsApi) Domains(keyword string) ([]*Domain, error) {
+	request := &alidns.DescribeDomainsRequest{
+		PageNumber: tea.Int64(1),
+		PageSize:   tea.Int64(100),
+	}
+	if keyword != "" {
+		request.KeyWord = &keyword
+	}
+
+	var result []*Domain
+	for {
+		response, err := api.describeDomains(request)
+		if err != nil {
+			return nil, err
+		}
+
+		if response.Body.Domains != nil {
+			result = append(result, response.Body.Domains.Domain...)
+		}
+
+		if response.Body.Domains == nil || len(response.Body.Domains.Domain) == 0 || len(result) >= int(tea.Int64Value(response.Body.TotalCount)) {
+			break
+		}
+
+		*request.PageNumber = *request.PageNumber + 1
+	}
+
+	return result, nil
+}
+
+// Lines lists the resolution lines the records of the domain can use.
+func (api *AlidnsApi) Lines() ([]*Line, error) {
+	response, err := api.describeSupportLines(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Line
+	if response.Body.RecordLines != nil {
+		for _, line := range response.Body.RecordLines.RecordLine {
+			result = append(result, &Line{
+				Code:   tea.StringValue(line.LineCode),
+				Name:   tea.StringValue(DefaultIfEmpty(line.LineDisplayName, line.LineName)),
+				Father: tea.StringValue(line.FatherCode),
+			})
+		}
+	}
+	return result, nil
+}
+
+// CustomLines lists the custom lines of the domain, made of IP address ranges.
+func (api *AlidnsApi) CustomLines() ([]*Line, error) {
+	request := &alidns.DescribeCustomLinesRequest{
+		PageNumber: tea.Int64(1),
+		PageSize:   tea.Int64(100),
+	}
+
+	var result []*Line
+	for {
+		response, err := api.describeCustomLines(request)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range response.Body.CustomLines {
+			result = append(result, &Line{
+				Code:   tea.StringValue(line.Code),
+				Name:   tea.StringValue(line.Name),
+				Custom: true,
+			})
+		}
+		if len(response.Body.CustomLines) == 0 || len(result) >= int(tea.Int32Value(response.Body.TotalItems)) {
+			break
+		}
+
+		*request.PageNumber = *request.PageNumber + 1
+	}
+
+	return result, nil
+}
+
+// SubDomainRecords lists the records of the subdomain of the RR, of the given
+// type if it is not empty. A RR such as '*.staging' lists the records of every
+// subdomain under 'staging' instead, including the wildcard record itself.
+func (api *AlidnsApi) SubDomainRecords(rr string, recordType string) ([]*DomainRecord, error) {
+	if parent := strings.TrimPrefix(rr, "*."); parent != rr {
+		query := &QueryInfo{RR: tea.String(parent)}
+		if recordType != "" {
+			query.Type = tea.String(recordType)
+		}
+		records, err := api.Query(query)
+		if err != nil {
+			return nil, err
+		}
+		var result []*DomainRecord
+		for _, record := range records {
+			// RRKeyWord is a fuzzy match, keep the subdomains of the parent only
+			if strings.HasSuffix(tea.StringValue(record.RR), "."+parent) {
+				result = append(result, record)
+			}
+		}
+		return result, nil
+	}
+
+	request := &alidns.DescribeSubDomainRecordsRequest{
+		SubDomain:  tea.String(api.SubDomain(rr)),
+		PageNumber: tea.Int64(1),
+		PageSize:   tea.Int64(500),
+	}
+	if recordType != "" {
+		request.Type = tea.String(recordType)
+	}
+
+	var result []*DomainRecord
+	for {
+		response, err := api.describeSubDomainRecords(request)
+		if err != nil {
+			return nil, err
+		}
+
+		records := response.Body.DomainRecords.Record
+		for _, record := range records {
+			result = append(result, &DomainRecord{
+				DomainName: record.DomainName,
+				Line:       record.Line,
+				Locked:     record.Locked,
+				Priority:   record.Priority,
+				RR:         record.RR,
+				RecordId:   record.RecordId,
+				Status:     record.Status,
+				TTL:        record.TTL,
+				Type:       record.Type,
+				Value:      record.Value,
+				Weight:     record.Weight,
+			})
+		}
+		if len(records) == 0 || len(result) >= int(tea.Int64Value(response.Body.TotalCount)) {
+			break
+		}
+
+		*request.PageNumber = *request.PageNumber + 1
+	}
+
+	return result, nil
+}
+
+// SubDomain returns the full name of the subdomain of the RR, '@' being the domain itself.
+func (api *AlidnsApi) SubDomain(rr string) string {
+	if rr == "" || rr == "@" {
+		return api.DomainName
+	}
+	return rr + "." + api.DomainName
+}
+
+// SlbSubDomains lists the subdomains having several records of a type, that
+// is the ones whose answers can be weighted. The rr filters the subdomains if
+// it is not empty.
+func (api *AlidnsApi) SlbSubDomains(rr string) ([]*SlbSubDomain, error) {
+	request := &alidns.DescribeDNSSLBSubDomainsRequest{
+		PageNumber: tea.Int64(1),
+		PageSize:   tea.Int64(100),
+	}
+	if rr != "" {
+		request.Rr = tea.String(rr)
+	}
+
+	var result []*SlbSubDomain
+	for {
+		response, err := api.describeDNSSLBSubDomains(request)
+		if err != nil {
+			return nil, err
+		}
+
+		subDomains := response.Body.SlbSubDomains.SlbSubDomain
+		result = append(result, subDomains...)
+		if len(subDomains) == 0 || len(result) >= int(tea.Int64Value(response.Body.TotalCount)) {
+			break
+		}
+
+		*request.PageNumber = *request.PageNumber + 1
+	}
+
+	return result, nil
+}
+
+// SetSlbStatus enables or disables the weighted round-robin of the records of
+// the given type of the subdomain, for a resolution line if line is not empty.
+func (api *AlidnsApi) SetSlbStatus(subDomain string, recordType string, line string, open bool) error {
+	request := &alidns.SetDNSSLBStatusRequest{
+		SubDomain: tea.String(subDomain),
+		Open:      tea.Bool(open),
+	}
+	if recordType != "" {
+		request.Type = tea.String(recordType)
+	}
+	if line != "" {
+		request.Line = tea.String(line)
+	}
+	_, err := api.setDNSSLBStatus(request)
+	return err
+}
+
+// SetWeight sets the weight of a record, from 1 to 100.
+func (api *AlidnsApi) SetWeight(recordId string, weight int32) error {
+	_, err := api.updateDNSSLBWeight(&alidns.UpdateDNSSLBWeightRequest{
+		RecordId: tea.String(recordId),
+		Weight:   tea.Int32(weight),
+	})
+	return err
+}
+
 func ErrMsg(err error) string {
 	if e, ok := err.(*tea.SDKError); ok {
 		return fmt.Sprintf("%s, %s", *e.Code, *e.Message)
@@ -0,0 +1,129 @@
+package utility
+
+import (
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+type ErrorClass int
+
+const (
+	// ERROR_RETRYABLE errors may succeed if the request is sent again, such as network errors.
+	ERROR_RETRYABLE ErrorClass = iota
+	// ERROR_THROTTLED errors are returned when the request rate exceeds the API quota.
+	ERROR_THROTTLED
+	// ERROR_FATAL errors will never succeed without a change of the configuration,
+	// such as invalid credentials or parameters.
+	ERROR_FATAL
+)
+
+func (class ErrorClass) String() string {
+	switch class {
+	case ERROR_RETRYABLE:
+		return "retryable"
+	case ERROR_THROTTLED:
+		return "throttled"
+	case ERROR_FATAL:
+		return "fatal"
+	default:
+		return ""
+	}
+}
+
+var fatalErrorCodes = []string{
+	"InvalidAccessKeyId",
+	"SignatureDoesNotMatch",
+	"IncompleteSignature",
+	"InvalidTimeStamp",
+	"Forbidden",
+	"NoPermission",
+	"IncorrectDomainUser",
+	"InvalidDomainName",
+	"InvalidRR",
+	"InvalidType",
+	"InvalidValue",
+	"InvalidTTL",
+	"InvalidLine",
+	"DomainRecordLocked",
+	"QuotaExceeded",
+}
+
+// retryableErrorCodes are returned with a 4xx status although the request may
+// succeed later, when the previous operation on the record is done.
+var retryableErrorCodes = []string{
+	"LastOperationNotFinished",
+	"OperationConflict",
+	"InternalError",
+	"ServiceUnavailable",
+}
+
+// ClassifyError tells whether a failed request is worth retrying.
+func ClassifyError(err error) ErrorClass {
+	e, ok := err.(*tea.SDKError)
+	if !ok {
+		// errors that are not returned by the API are network errors
+		return ERROR_RETRYABLE
+	}
+
+	code := tea.StringValue(e.Code)
+	if strings.HasPrefix(code, "Throttling") || tea.IntValue(e.StatusCode) == 429 {
+		return ERROR_THROTTLED
+	}
+	if hasErrorCode(err, retryableErrorCodes) {
+		return ERROR_RETRYABLE
+	}
+	if hasErrorCode(err, fatalErrorCodes) {
+		return ERROR_FATAL
+	}
+
+	status := tea.IntValue(e.StatusCode)
+	if status >= 400 && status < 500 && status != 408 {
+		return ERROR_FATAL
+	}
+	return ERROR_RETRYABLE
+}
+
+var authErrorCodes = []string{
+	"InvalidAccessKeyId",
+	"SignatureDoesNotMatch",
+	"IncompleteSignature",
+	"InvalidSecurityToken",
+	"Forbidden",
+	"NoPermission",
+}
+
+var notFoundErrorCodes = []string{
+	"DomainRecordNotBelongToUser",
+	"IncorrectDomainUser",
+	"InvalidDomainName.NoExist",
+	"InvalidRR.NoExist",
+	"DomainRecordNotExist",
+	"DomainNotExist",
+}
+
+func hasErrorCode(err error, codes []string) bool {
+	e, ok := err.(*tea.SDKError)
+	if !ok {
+		return false
+	}
+	code := tea.StringValue(e.Code)
+	for _, prefix := range codes {
+		if code == prefix || strings.HasPrefix(code, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAuthError tells whether the request was refused because of the credentials
+// or the permissions of the access key.
+func IsAuthError(err error) bool {
+	return hasErrorCode(err, authErrorCodes)
+}
+
+// IsNotFoundError tells whether the domain or the record of the request does
+// not exist, or does not belong to the account.
+func IsNotFoundError(err error) bool {
+	return hasErrorCode(err, notFoundErrorCodes)
+}
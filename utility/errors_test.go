@@ -0,0 +1,37 @@
+package utility
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+)
+
+func TestClassifyError(t *testing.T) {
+	sdkError := func(code string, status int) error {
+		return &tea.SDKError{Code: tea.String(code), StatusCode: tea.Int(status)}
+	}
+
+	for _, c := range []struct {
+		err  error
+		want ErrorClass
+	}{
+		{errors.New("connection refused"), ERROR_RETRYABLE},
+		{sdkError("Throttling.User", 400), ERROR_THROTTLED},
+		{sdkError("Throttling.Api", 400), ERROR_THROTTLED},
+		{sdkError("Throttling", 503), ERROR_THROTTLED},
+		{sdkError("Unknown", 429), ERROR_THROTTLED},
+		{sdkError("LastOperationNotFinished", 400), ERROR_RETRYABLE},
+		{sdkError("OperationConflict", 409), ERROR_RETRYABLE},
+		{sdkError("ServiceUnavailable", 503), ERROR_RETRYABLE},
+		{sdkError("InvalidAccessKeyId.NotFound", 404), ERROR_FATAL},
+		{sdkError("InvalidRR", 400), ERROR_FATAL},
+		{sdkError("MissingParameter", 400), ERROR_FATAL},
+		{sdkError("RequestTimeout", 408), ERROR_RETRYABLE},
+		{sdkError("InternalError", 500), ERROR_RETRYABLE},
+	} {
+		if got := ClassifyError(c.err); got != c.want {
+			t.Errorf("%v: got %s, want %s", c.err, got, c.want)
+		}
+	}
+}
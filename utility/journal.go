@@ -0,0 +1,108 @@
+package utility
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const journalSocket = "/run/systemd/journal/socket"
+
+// journal sends the log messages to journald with the native protocol, so that
+// the priority and the fields of the messages are kept.
+var journal *journalSink
+
+type journalSink struct {
+	conn       *net.UnixConn
+	identifier string
+}
+
+// syslog priorities of the log levels
+func (level LogLevel) priority() int {
+	switch level {
+	case LOG_DEBUG:
+		return 7
+	case LOG_INFO:
+		return 6
+	case LOG_WARNING:
+		return 4
+	case LOG_ERROR:
+		return 3
+	default:
+		return 2
+	}
+}
+
+func writeJournalField(buffer *bytes.Buffer, name string, value string) {
+	buffer.WriteString(name)
+	if strings.ContainsRune(value, '\n') {
+		// values spanning several lines are written with their length
+		buffer.WriteByte('\n')
+		binary.Write(buffer, binary.LittleEndian, uint64(len(value)))
+		buffer.WriteString(value)
+	} else {
+		buffer.WriteByte('=')
+		buffer.WriteString(value)
+	}
+	buffer.WriteByte('\n')
+}
+
+// journalFieldName converts a field name to the form journald accepts, upper
+// case letters, digits and underscores, not starting with an underscore.
+func journalFieldName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	return strings.TrimLeft(name, "_")
+}
+
+func (j *journalSink) send(level LogLevel, fields Fields, message string) error {
+	var buffer bytes.Buffer
+	writeJournalField(&buffer, "MESSAGE", message)
+	writeJournalField(&buffer, "PRIORITY", strconv.Itoa(level.priority()))
+	writeJournalField(&buffer, "SYSLOG_IDENTIFIER", j.identifier)
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if field := journalFieldName(name); field != "" {
+			writeJournalField(&buffer, field, fields[name])
+		}
+	}
+
+	_, err := j.conn.Write(buffer.Bytes())
+	return err
+}
+
+// UseJournal logs to journald when the standard output is connected to the
+// journal, which systemd tells by setting JOURNAL_STREAM. It returns false if
+// the messages still go to the standard output.
+func UseJournal() bool {
+	stream := os.Getenv("JOURNAL_STREAM")
+	if stream == "" || !isJournalStream(os.Stdout, stream) {
+		return false
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		Warningf("Failed to connect to journald, keep logging to the standard output: %s", err.Error())
+		return false
+	}
+	journal = &journalSink{conn: conn, identifier: filepath.Base(os.Args[0])}
+	return true
+}
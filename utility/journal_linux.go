@@ -0,0 +1,17 @@
+package utility
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// isJournalStream tells whether the file is the stream described by
+// JOURNAL_STREAM, which is formatted as "device:inode".
+func isJournalStream(file *os.File, stream string) bool {
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(int(file.Fd()), &stat); err != nil {
+		return false
+	}
+	return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino) == stream
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package utility
+
+import "os"
+
+// journald only runs on Linux.
+func isJournalStream(file *os.File, stream string) bool {
+	return false
+}
@@ -0,0 +1,113 @@
+//go:build !windows
+
+package utility
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteJournalField(t *testing.T) {
+	var buffer bytes.Buffer
+	writeJournalField(&buffer, "MESSAGE", "one line")
+	if got, want := buffer.String(), "MESSAGE=one line\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// a value spanning several lines is written with its length
+	buffer.Reset()
+	writeJournalField(&buffer, "MESSAGE", "first\nsecond")
+	var want bytes.Buffer
+	want.WriteString("MESSAGE\n")
+	binary.Write(&want, binary.LittleEndian, uint64(len("first\nsecond")))
+	want.WriteString("first\nsecond\n")
+	if !bytes.Equal(buffer.Bytes(), want.Bytes()) {
+		t.Errorf("got %q, want %q", buffer.Bytes(), want.Bytes())
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	for name, want := range map[string]string{
+		"RR":          "RR",
+		"domain":      "DOMAIN",
+		"record-id":   "RECORD_ID",
+		"_hidden":     "HIDDEN",
+		"__":          "",
+		"line.value2": "LINE_VALUE2",
+	} {
+		if got := journalFieldName(name); got != want {
+			t.Errorf("'%s': got '%s', want '%s'", name, got, want)
+		}
+	}
+}
+
+// parseJournal decodes the fields of a datagram of the native protocol.
+func parseJournal(t *testing.T, data []byte) map[string]string {
+	fields := map[string]string{}
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			t.Fatalf("the field %q does not end with a newline", data)
+		}
+		line := data[:end]
+		if i := bytes.IndexByte(line, '='); i >= 0 {
+			fields[string(line[:i])] = string(line[i+1:])
+			data = data[end+1:]
+			continue
+		}
+		data = data[end+1:]
+		if len(data) < 8 {
+			t.Fatalf("the length of the field '%s' is truncated", line)
+		}
+		n := binary.LittleEndian.Uint64(data)
+		data = data[8:]
+		if uint64(len(data)) < n+1 || data[n] != '\n' {
+			t.Fatalf("the value of the field '%s' is truncated", line)
+		}
+		fields[string(line)] = string(data[:n])
+		data = data[n+1:]
+	}
+	return fields
+}
+
+func TestJournalSend(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "journal")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	j := &journalSink{conn: conn, identifier: "alidns"}
+	if err := j.send(LOG_WARNING, Fields{"RR": "www", "record-id": "123"}, "first\nsecond"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buffer := make([]byte, 4096)
+	n, err := server.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := parseJournal(t, buffer[:n])
+	for name, want := range map[string]string{
+		"MESSAGE":           "first\nsecond",
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "alidns",
+		"RR":                "www",
+		"RECORD_ID":         "123",
+	} {
+		if fields[name] != want {
+			t.Errorf("%s: got %q, want %q", name, fields[name], want)
+		}
+	}
+}
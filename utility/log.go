@@ -3,9 +3,11 @@ package utility
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path"
+	"strings"
 )
 
 const (
@@ -109,73 +111,147 @@ func SetLogFile(fileName string) error {
 		return err
 	}
 
-	logDebug.SetOutput(file)
-	logInfo.SetOutput(file)
-	logWarning.SetOutput(file)
-	logError.SetOutput(file)
-	logFatal.SetOutput(file)
+	SetLogOutput(file)
 
 	return nil
 }
 
-func Debug(v ...any) {
-	if logLevel == LOG_DEBUG {
-		logDebug.Println(v...)
+// SetLogOutput writes the messages to w, such as os.Stderr to keep the output
+// of a command clean.
+func SetLogOutput(w io.Writer) {
+	journal = nil
+	logDebug.SetOutput(w)
+	logInfo.SetOutput(w)
+	logWarning.SetOutput(w)
+	logError.SetOutput(w)
+	logFatal.SetOutput(w)
+}
+
+// Fields are structured data attached to a message, such as the record it is
+// about. They are kept as journal fields when logging to journald.
+type Fields map[string]string
+
+func loggerOf(level LogLevel) *log.Logger {
+	switch level {
+	case LOG_DEBUG:
+		return logDebug
+	case LOG_INFO:
+		return logInfo
+	case LOG_WARNING:
+		return logWarning
+	case LOG_ERROR:
+		return logError
+	default:
+		return logFatal
 	}
 }
 
-func Debugf(format string, v ...any) {
-	if logLevel == LOG_DEBUG {
-		logDebug.Printf(format, v...)
+func output(level LogLevel, fields Fields, message string) {
+	if level < logLevel {
+		return
 	}
+	if journal != nil {
+		if err := journal.send(level, fields, message); err == nil {
+			return
+		}
+	}
+	loggerOf(level).Println(message)
+}
+
+func Debug(v ...any) {
+	output(LOG_DEBUG, nil, sprintln(v...))
+}
+
+func Debugf(format string, v ...any) {
+	output(LOG_DEBUG, nil, fmt.Sprintf(format, v...))
 }
 
 func Info(v ...any) {
-	if logLevel <= LOG_INFO {
-		logInfo.Println(v...)
-	}
+	output(LOG_INFO, nil, sprintln(v...))
 }
 
 func Infof(format string, v ...any) {
-	if logLevel <= LOG_INFO {
-		logInfo.Printf(format, v...)
-	}
+	output(LOG_INFO, nil, fmt.Sprintf(format, v...))
 }
 
 func Warning(v ...any) {
-	if logLevel <= LOG_WARNING {
-		logWarning.Println(v...)
-	}
+	output(LOG_WARNING, nil, sprintln(v...))
 }
 
 func Warningf(format string, v ...any) {
-	if logLevel <= LOG_WARNING {
-		logWarning.Printf(format, v...)
-	}
+	output(LOG_WARNING, nil, fmt.Sprintf(format, v...))
 }
 
 func Error(v ...any) {
-	if logLevel <= LOG_ERROR {
-		logError.Println(v...)
-	}
+	output(LOG_ERROR, nil, sprintln(v...))
 }
 
 func Errorf(format string, v ...any) {
-	if logLevel <= LOG_ERROR {
-		logError.Printf(format, v...)
-	}
+	output(LOG_ERROR, nil, fmt.Sprintf(format, v...))
 }
 
 func Fatal(v ...any) {
-	if logLevel <= LOG_FATAL {
-		logFatal.Fatalln(v...)
-	}
+	output(LOG_FATAL, nil, sprintln(v...))
+	os.Exit(1)
 }
 
 func Fatalf(format string, v ...any) {
-	if logLevel <= LOG_FATAL {
-		logFatal.Fatalf(format, v...)
+	output(LOG_FATAL, nil, fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+
+// sprintln formats like log.Println, without the trailing newline.
+func sprintln(v ...any) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
+
+// Logger logs messages with the same fields attached, a nil Logger logs
+// without fields.
+type Logger struct {
+	fields Fields
+}
+
+func WithFields(fields Fields) *Logger {
+	return &Logger{fields: fields}
+}
+
+func (l *Logger) Fields() Fields {
+	if l == nil {
+		return nil
 	}
+	return l.fields
+}
+
+func (l *Logger) Debug(v ...any) {
+	output(LOG_DEBUG, l.Fields(), sprintln(v...))
+}
+
+func (l *Logger) Debugf(format string, v ...any) {
+	output(LOG_DEBUG, l.Fields(), fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Info(v ...any) {
+	output(LOG_INFO, l.Fields(), sprintln(v...))
+}
+
+func (l *Logger) Infof(format string, v ...any) {
+	output(LOG_INFO, l.Fields(), fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Warning(v ...any) {
+	output(LOG_WARNING, l.Fields(), sprintln(v...))
+}
+
+func (l *Logger) Warningf(format string, v ...any) {
+	output(LOG_WARNING, l.Fields(), fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) Error(v ...any) {
+	output(LOG_ERROR, l.Fields(), sprintln(v...))
+}
+
+func (l *Logger) Errorf(format string, v ...any) {
+	output(LOG_ERROR, l.Fields(), fmt.Sprintf(format, v...))
 }
 
 func init() {